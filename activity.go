@@ -0,0 +1,262 @@
+package onlyoffice
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+// defaultSessionGap separates editing sessions of a user
+const defaultSessionGap = 30 * time.Minute
+
+// ActivityOptions configures DocumentActivity and HistoryActivity
+type ActivityOptions struct {
+	// Since and Until restrict the analysis to events in [Since, Until).
+	// Zero values leave the range open.
+	Since time.Time
+	Until time.Time
+	// SessionGap is the longest pause within an editing session of a user.
+	// Defaults to 30 minutes.
+	SessionGap time.Duration
+}
+
+// ActivityEventType is the type of an ActivityEvent
+type ActivityEventType string
+
+const (
+	// ActivityVersion is a version saved by the Document Server
+	ActivityVersion ActivityEventType = "version"
+	// ActivityChange is a change entry of a version
+	ActivityChange ActivityEventType = "change"
+)
+
+// ActivityEvent is an entry of the activity timeline
+type ActivityEvent struct {
+	Type    ActivityEventType `json:"type"`
+	Time    time.Time         `json:"time"`
+	DocID   string            `json:"docId"`
+	Version int               `json:"version"`
+	User    *models.User      `json:"user,omitempty"`
+	// RestoredFrom is set on versions created by RestoreVersion
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+}
+
+// ActivitySession is a period of continuous editing by a user on a document
+type ActivitySession struct {
+	DocID   string    `json:"docId"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Edits   int       `json:"edits"`
+	Seconds int64     `json:"seconds"`
+}
+
+// UserActivity holds the contribution statistics of a user
+type UserActivity struct {
+	User models.User `json:"user"`
+	// Versions counts the versions saved with the user as author
+	Versions int `json:"versions"`
+	// Edits counts the change entries of the user. Versions without change
+	// entries count as one edit of their author.
+	Edits         int               `json:"edits"`
+	Documents     []string          `json:"documents"`
+	FirstEdit     time.Time         `json:"firstEdit"`
+	LastEdit      time.Time         `json:"lastEdit"`
+	Sessions      []ActivitySession `json:"sessions"`
+	ActiveSeconds int64             `json:"activeSeconds"`
+}
+
+// Activity summarizes the history of one or more documents
+type Activity struct {
+	Documents []string `json:"documents"`
+	Versions  int      `json:"versions"`
+	Edits     int      `json:"edits"`
+	// Start and End are the times of the first and last event
+	Start            time.Time    `json:"start"`
+	End              time.Time    `json:"end"`
+	FirstContributor *models.User `json:"firstContributor,omitempty"`
+	LastContributor  *models.User `json:"lastContributor,omitempty"`
+	// Users are ordered by edits, most active first
+	Users []UserActivity `json:"users"`
+	// Timeline lists versions and their change entries, oldest first
+	Timeline []ActivityEvent `json:"timeline"`
+}
+
+// DocumentActivity analyzes the stored history of a document
+func (c *Client) DocumentActivity(docID string, opts ActivityOptions) (*Activity, error) {
+	return c.HistoryActivity([]string{docID}, opts)
+}
+
+// HistoryActivity analyzes the stored history of several documents
+// together, merging their timelines and the statistics of each user
+func (c *Client) HistoryActivity(docIDs []string, opts ActivityOptions) (*Activity, error) {
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	history := make(map[string][]HistoryVersion, len(docIDs))
+	for _, docID := range docIDs {
+		versions, err := store.Versions(ctx, docID)
+		if err != nil {
+			return nil, err
+		}
+		history[docID] = versions
+	}
+	return analyzeActivity(docIDs, history, opts), nil
+}
+
+// activityEdit is a single edit by a user
+type activityEdit struct {
+	docID string
+	time  time.Time
+	user  *models.User
+}
+
+// analyzeActivity implements HistoryActivity
+func analyzeActivity(docIDs []string, history map[string][]HistoryVersion, opts ActivityOptions) *Activity {
+	if opts.SessionGap <= 0 {
+		opts.SessionGap = defaultSessionGap
+	}
+	inRange := func(t time.Time) bool {
+		return (opts.Since.IsZero() || !t.Before(opts.Since)) && (opts.Until.IsZero() || t.Before(opts.Until))
+	}
+
+	a := &Activity{
+		Documents: docIDs,
+		Users:     []UserActivity{},
+		Timeline:  []ActivityEvent{},
+	}
+	var edits []activityEdit
+	for _, docID := range docIDs {
+		for _, v := range history[docID] {
+			if inRange(v.Created) {
+				a.Timeline = append(a.Timeline, ActivityEvent{
+					Type:         ActivityVersion,
+					Time:         v.Created,
+					DocID:        docID,
+					Version:      v.Version,
+					User:         v.User,
+					RestoredFrom: v.RestoredFrom,
+				})
+				a.Versions++
+			}
+			if len(v.ChangesData) == 0 {
+				if v.User != nil && inRange(v.Created) {
+					edits = append(edits, activityEdit{docID: docID, time: v.Created, user: v.User})
+				}
+				continue
+			}
+			for _, change := range v.ChangesData {
+				t, err := time.Parse(historyTimeFormat, change.Created)
+				if err != nil {
+					t = v.Created
+				}
+				if !inRange(t) {
+					continue
+				}
+				user := change.User
+				a.Timeline = append(a.Timeline, ActivityEvent{
+					Type:    ActivityChange,
+					Time:    t,
+					DocID:   docID,
+					Version: v.Version,
+					User:    &user,
+				})
+				edits = append(edits, activityEdit{docID: docID, time: t, user: &user})
+			}
+		}
+	}
+
+	sort.SliceStable(a.Timeline, func(i, j int) bool {
+		return a.Timeline[i].Time.Before(a.Timeline[j].Time)
+	})
+	if n := len(a.Timeline); n > 0 {
+		a.Start = a.Timeline[0].Time
+		a.End = a.Timeline[n-1].Time
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].time.Before(edits[j].time)
+	})
+	a.Edits = len(edits)
+	if n := len(edits); n > 0 {
+		a.FirstContributor = edits[0].user
+		a.LastContributor = edits[n-1].user
+	}
+
+	users := make(map[string]*UserActivity)
+	var order []string
+	user := func(u *models.User) *UserActivity {
+		id := activityUserID(u)
+		ua, ok := users[id]
+		if !ok {
+			ua = &UserActivity{User: *u, Documents: []string{}, Sessions: []ActivitySession{}}
+			users[id] = ua
+			order = append(order, id)
+		}
+		return ua
+	}
+	for _, e := range edits {
+		u := user(e.user)
+		if u.Edits == 0 {
+			u.FirstEdit = e.time
+		}
+		u.Edits++
+		u.LastEdit = e.time
+		addSessionEdit(u, e, opts.SessionGap)
+	}
+	for _, ev := range a.Timeline {
+		if ev.Type == ActivityVersion && ev.User != nil {
+			user(ev.User).Versions++
+		}
+	}
+
+	for _, id := range order {
+		u := users[id]
+		seen := make(map[string]bool)
+		for _, s := range u.Sessions {
+			u.ActiveSeconds += s.Seconds
+			if !seen[s.DocID] {
+				seen[s.DocID] = true
+				u.Documents = append(u.Documents, s.DocID)
+			}
+		}
+		a.Users = append(a.Users, *u)
+	}
+	sort.SliceStable(a.Users, func(i, j int) bool {
+		return a.Users[i].Edits > a.Users[j].Edits
+	})
+	return a
+}
+
+// addSessionEdit adds an edit to the current session of the user on the
+// document, or starts a new one after a pause longer than gap. Edits are
+// added in chronological order.
+func addSessionEdit(u *UserActivity, e activityEdit, gap time.Duration) {
+	for i := len(u.Sessions) - 1; i >= 0; i-- {
+		s := &u.Sessions[i]
+		if s.DocID != e.docID {
+			continue
+		}
+		if e.time.Sub(s.End) <= gap {
+			s.End = e.time
+			s.Edits++
+			s.Seconds = int64(s.End.Sub(s.Start) / time.Second)
+			return
+		}
+		break
+	}
+	u.Sessions = append(u.Sessions, ActivitySession{DocID: e.docID, Start: e.time, End: e.time, Edits: 1})
+}
+
+// activityUserID identifies a user in the statistics, by ID and by name for
+// users without one
+func activityUserID(u *models.User) string {
+	if u.Id != "" {
+		return "id:" + u.Id
+	}
+	return "name:" + u.Name
+}
@@ -0,0 +1,81 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func TestHistoryActivity(t *testing.T) {
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := models.User{Id: "u1", Name: "Alice"}
+	bob := models.User{Id: "u2", Name: "Bob"}
+	change := func(created string, user models.User) models.Change {
+		return models.Change{Created: "2026-01-21 " + created, User: user}
+	}
+	saves := []struct {
+		docID   string
+		changes []models.Change
+	}{
+		{"a.docx", []models.Change{change("09:00:00", alice), change("09:10:00", alice), change("09:20:00", bob)}},
+		{"a.docx", []models.Change{change("10:30:00", alice)}},
+		{"b.docx", []models.Change{change("09:05:00", bob), change("09:15:00", bob)}},
+	}
+	for _, s := range saves {
+		if _, err := st.Put(context.Background(), s.docID, strings.NewReader("content")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.CreateHistory(s.docID, models.Callback{Key: "k", History: models.History{Changes: s.changes}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := client.HistoryActivity([]string{"a.docx", "b.docx"}, onlyoffice.ActivityOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Versions != 3 || a.Edits != 6 || len(a.Timeline) != 9 {
+		t.Errorf("Unexpected totals: %d versions, %d edits, %d events", a.Versions, a.Edits, len(a.Timeline))
+	}
+	if a.FirstContributor.Name != "Alice" || a.LastContributor.Name != "Alice" {
+		t.Errorf("Unexpected contributors %s, %s", a.FirstContributor.Name, a.LastContributor.Name)
+	}
+	for i := 1; i < len(a.Timeline); i++ {
+		if a.Timeline[i].Time.Before(a.Timeline[i-1].Time) {
+			t.Fatalf("Timeline out of order at %d", i)
+		}
+	}
+
+	if len(a.Users) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(a.Users))
+	}
+	// Alice and Bob both have 3 edits; Alice comes first
+	u := a.Users[0]
+	if u.User.Name != "Alice" || u.Edits != 3 || u.Versions != 1 || len(u.Sessions) != 2 || u.ActiveSeconds != 600 {
+		t.Errorf("Unexpected activity of Alice %+v", u)
+	}
+	u = a.Users[1]
+	if u.User.Name != "Bob" || u.Edits != 3 || u.Versions != 2 || len(u.Documents) != 2 || len(u.Sessions) != 2 {
+		t.Errorf("Unexpected activity of Bob %+v", u)
+	}
+
+	// Restricted to a time window and a document
+	since := time.Date(2026, 1, 21, 10, 0, 0, 0, time.UTC)
+	a, err = client.DocumentActivity("a.docx", onlyoffice.ActivityOptions{Since: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Edits != 1 || a.Versions != 1 || len(a.Users) != 1 || a.Users[0].User.Name != "Alice" {
+		t.Errorf("Unexpected activity since 10:00 %+v", a)
+	}
+}
@@ -0,0 +1,349 @@
+package onlyoffice
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+const (
+	// archiveFormat identifies history archives in their manifest
+	archiveFormat = "go-onlyoffice-history"
+	// archiveFormatVersion is the manifest version written by ExportHistory
+	archiveFormatVersion = 1
+	// archiveManifest is the name of the manifest in the archive
+	archiveManifest = "manifest.json"
+
+	// maxArchiveEntries limits the number of files in an imported archive
+	maxArchiveEntries = 100000
+	// maxArchiveFileSize limits the uncompressed size of an imported file
+	maxArchiveFileSize = 4 << 30
+	// maxArchiveManifestSize limits the uncompressed size of the manifest
+	maxArchiveManifestSize = 64 << 20
+)
+
+// ErrChecksumMismatch is wrapped by ImportHistory errors for archive files
+// whose content does not match the manifest
+var ErrChecksumMismatch = errors.New("onlyoffice: archive checksum mismatch")
+
+// HistoryManifest describes a history archive
+type HistoryManifest struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"formatVersion"`
+	DocID         string    `json:"docId"`
+	Exported      time.Time `json:"exported"`
+	// Document is the current document, if the archive contains it
+	Document *ArchiveFile     `json:"document,omitempty"`
+	Versions []ArchiveVersion `json:"versions"`
+}
+
+// ArchiveVersion is a version in a history archive
+type ArchiveVersion struct {
+	HistoryVersion
+	Files map[HistoryArtifact]ArchiveFile `json:"files,omitempty"`
+}
+
+// ArchiveFile is a file in a history archive
+type ArchiveFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ExportHistory writes a zip archive with the current document, every
+// version with its files, and a manifest.json holding the metadata and
+// SHA-256 checksums of all files.
+func (c *Client) ExportHistory(docID string, w io.Writer) error {
+	ctx := context.Background()
+	store, err := c.historyStore()
+	if err != nil {
+		return err
+	}
+	versions, err := store.Versions(ctx, docID)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	manifest := HistoryManifest{
+		Format:        archiveFormat,
+		FormatVersion: archiveFormatVersion,
+		DocID:         docID,
+		Exported:      time.Now().UTC().Truncate(time.Second),
+		Versions:      make([]ArchiveVersion, 0, len(versions)),
+	}
+
+	if st := c.config.Storage; st != nil {
+		r, _, err := st.Get(ctx, docID)
+		switch {
+		case err == nil:
+			file, err := writeArchiveFile(zw, "document/"+path.Base(docID), r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			manifest.Document = file
+		case !storage.IsNotExist(err):
+			return err
+		}
+	}
+
+	for _, v := range versions {
+		av := ArchiveVersion{HistoryVersion: v, Files: make(map[HistoryArtifact]ArchiveFile)}
+		dir := path.Join("versions", strconv.Itoa(v.Version))
+		for _, artifact := range v.Artifacts {
+			name, err := artifactName(&v, artifact)
+			if err != nil {
+				return err
+			}
+			r, _, err := store.OpenArtifact(ctx, docID, v.Version, artifact)
+			if err != nil {
+				return fmt.Errorf("export: version %d: %w", v.Version, err)
+			}
+			file, err := writeArchiveFile(zw, path.Join(dir, name), r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			av.Files[artifact] = *file
+		}
+		manifest.Versions = append(manifest.Versions, av)
+	}
+
+	mw, err := zw.Create(archiveManifest)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeArchiveFile copies r into the archive and records its checksum
+func writeArchiveFile(zw *zip.Writer, name string, r io.Reader) (*ArchiveFile, error) {
+	w, err := zw.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		return nil, fmt.Errorf("export: %s: %w", name, err)
+	}
+	return &ArchiveFile{Path: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ImportHistory rebuilds the history of a document from an archive written
+// by ExportHistory, into the configured HistoryStore and Storage. docID
+// overrides the document ID of the archive when not empty. Every file is
+// verified against the manifest before anything is written, and archives
+// with too many entries or oversized files are rejected. The target
+// document must have no history, nor a current file when the archive holds
+// one, and a failed import removes what it wrote so it can be retried.
+// Version numbers are kept when the store implements HistoryImporter; other
+// stores number the versions anew.
+func (c *Client) ImportHistory(r io.ReaderAt, size int64, docID string) (*HistoryManifest, error) {
+	ctx := context.Background()
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+	if len(zr.File) > maxArchiveEntries {
+		return nil, fmt.Errorf("import: archive has %d entries, more than %d", len(zr.File), maxArchiveEntries)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	manifest, err := readManifest(files)
+	if err != nil {
+		return nil, err
+	}
+	if docID == "" {
+		docID = manifest.DocID
+	}
+
+	if err := checkManifest(manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Document != nil {
+		if err := verifyArchiveFile(files, *manifest.Document); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range manifest.Versions {
+		for _, file := range v.Files {
+			if err := verifyArchiveFile(files, file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	existing, err := store.Versions(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("import: history of %s is not empty", docID)
+	}
+	st := c.config.Storage
+	if manifest.Document != nil && st != nil {
+		if _, err := st.Stat(ctx, docID); err == nil {
+			return nil, fmt.Errorf("import: document %s already exists", docID)
+		} else if !storage.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	var imported []int
+	fail := func(err error) (*HistoryManifest, error) {
+		for i := len(imported) - 1; i >= 0; i-- {
+			store.DeleteVersion(ctx, docID, imported[i])
+		}
+		return nil, err
+	}
+	for _, v := range manifest.Versions {
+		n, err := importArchiveVersion(ctx, store, docID, files, v)
+		if err != nil {
+			return fail(fmt.Errorf("import: version %d: %w", v.Version, err))
+		}
+		imported = append(imported, n)
+	}
+
+	if manifest.Document != nil && st != nil {
+		rc, err := files[manifest.Document.Path].Open()
+		if err != nil {
+			return fail(err)
+		}
+		_, err = st.Put(ctx, docID, io.LimitReader(rc, manifest.Document.Size))
+		rc.Close()
+		if err != nil {
+			return fail(fmt.Errorf("import: store document: %w", err))
+		}
+	}
+	return manifest, nil
+}
+
+// checkManifest validates the versions of a manifest, as their numbers and
+// file types name stored files
+func checkManifest(manifest *HistoryManifest) error {
+	seen := make(map[int]bool, len(manifest.Versions))
+	for _, v := range manifest.Versions {
+		if v.Version < 1 || seen[v.Version] {
+			return fmt.Errorf("import: invalid version %d", v.Version)
+		}
+		seen[v.Version] = true
+		if err := CheckHistoryVersion(&v.HistoryVersion); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		for artifact := range v.Files {
+			if artifact != ArtifactDocument && artifact != ArtifactChanges {
+				return fmt.Errorf("import: version %d: unknown artifact %q", v.Version, artifact)
+			}
+		}
+	}
+	return nil
+}
+
+// readManifest decodes and checks the manifest of an archive
+func readManifest(files map[string]*zip.File) (*HistoryManifest, error) {
+	f, ok := files[archiveManifest]
+	if !ok {
+		return nil, errors.New("import: archive has no manifest")
+	}
+	if f.UncompressedSize64 > maxArchiveManifestSize {
+		return nil, fmt.Errorf("import: manifest exceeds %d bytes", maxArchiveManifestSize)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var manifest HistoryManifest
+	if err := json.NewDecoder(io.LimitReader(rc, maxArchiveManifestSize)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("import: decode manifest: %w", err)
+	}
+	if manifest.Format != archiveFormat || manifest.FormatVersion != archiveFormatVersion {
+		return nil, fmt.Errorf("import: unsupported archive format %q version %d", manifest.Format, manifest.FormatVersion)
+	}
+	return &manifest, nil
+}
+
+// verifyArchiveFile checks the size and checksum of an archive file. No more
+// than the size in the manifest is read, whatever the zip header claims.
+func verifyArchiveFile(files map[string]*zip.File, file ArchiveFile) error {
+	f, ok := files[file.Path]
+	if !ok {
+		return fmt.Errorf("import: %s missing from archive", file.Path)
+	}
+	if file.Size < 0 || file.Size > maxArchiveFileSize {
+		return fmt.Errorf("import: %s exceeds %d bytes", file.Path, int64(maxArchiveFileSize))
+	}
+	if f.UncompressedSize64 != uint64(file.Size) {
+		return fmt.Errorf("import: %s: %w", file.Path, ErrChecksumMismatch)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(rc, file.Size+1))
+	if err != nil {
+		return fmt.Errorf("import: %s: %w", file.Path, err)
+	}
+	if n != file.Size || hex.EncodeToString(h.Sum(nil)) != file.SHA256 {
+		return fmt.Errorf("import: %s: %w", file.Path, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// importArchiveVersion stores a version of an archive and returns the
+// number it was stored under
+func importArchiveVersion(ctx context.Context, store HistoryStore, docID string, files map[string]*zip.File, v ArchiveVersion) (int, error) {
+	var artifacts HistoryArtifacts
+	for artifact, file := range v.Files {
+		rc, err := files[file.Path].Open()
+		if err != nil {
+			return 0, err
+		}
+		defer rc.Close()
+
+		switch artifact {
+		case ArtifactDocument:
+			artifacts.Document = io.LimitReader(rc, file.Size)
+		case ArtifactChanges:
+			artifacts.Changes = io.LimitReader(rc, file.Size)
+		}
+	}
+
+	if importer, ok := store.(HistoryImporter); ok {
+		return v.Version, importer.ImportVersion(ctx, docID, v.HistoryVersion, artifacts)
+	}
+	stored, err := store.AddVersion(ctx, docID, &v.HistoryVersion, artifacts)
+	if err != nil {
+		return 0, err
+	}
+	return stored.Version, nil
+}
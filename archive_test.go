@@ -0,0 +1,294 @@
+package onlyoffice_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func exportHistory(t *testing.T, client *onlyoffice.Client, docID string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := client.ExportHistory(docID, &buf); err != nil {
+		t.Fatalf("ExportHistory failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestHistoryArchive(t *testing.T) {
+	client := newPruneClient(t, -3*time.Hour, -2*time.Hour, -time.Hour)
+	if _, err := client.Prune("a.docx", onlyoffice.RetentionPolicy{KeepLast: 2}, false); err != nil {
+		t.Fatal(err)
+	}
+	archive := exportHistory(t, client, "a.docx")
+
+	st := storage.NewMemory()
+	target, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := target.ImportHistory(bytes.NewReader(archive), int64(len(archive)), "b.docx")
+	if err != nil {
+		t.Fatalf("ImportHistory failed: %v", err)
+	}
+	if manifest.DocID != "a.docx" || len(manifest.Versions) != 2 || manifest.Document == nil {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+
+	// Numbering survives the pruned version
+	history, err := target.GetHistory("b.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Version != 2 || history[1].Version != 3 {
+		t.Fatalf("Unexpected history %+v", history)
+	}
+	if readObject(t, st, "b.docx") != "content" {
+		t.Error("Current document not imported")
+	}
+	r, _, err := target.OpenHistoryArtifact("b.docx", 3, onlyoffice.ArtifactChanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "changes" {
+		t.Errorf("Changes = %q", data)
+	}
+
+	// The target history must be empty
+	if _, err := target.ImportHistory(bytes.NewReader(archive), int64(len(archive)), "b.docx"); err == nil {
+		t.Error("Expected error importing into an existing history")
+	}
+}
+
+func TestHistoryArchiveTampered(t *testing.T) {
+	client, _ := newRestoreClient(t)
+	archive := exportHistory(t, client, "a.docx")
+
+	// Rewrite the archive with one version file changed
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		w, err := zw.Create(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(f.Name, "versions/1/") {
+			w.Write([]byte("tampered"))
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(w, rc)
+		rc.Close()
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	st := storage.NewMemory()
+	target, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := buf.Bytes()
+	if _, err := target.ImportHistory(bytes.NewReader(tampered), int64(len(tampered)), ""); !errors.Is(err, onlyoffice.ErrChecksumMismatch) {
+		t.Fatalf("Expected checksum mismatch, got %v", err)
+	}
+
+	// Nothing is written
+	if n, err := target.CountVersion("a.docx"); err != nil || n != 0 {
+		t.Errorf("Expected no versions, got %d, %v", n, err)
+	}
+	if _, _, err := st.Get(context.Background(), "a.docx"); !storage.IsNotExist(err) {
+		t.Errorf("Expected missing document, got %v", err)
+	}
+}
+
+// writeArchive builds an archive with the given manifest and files, with
+// checksums matching the content
+func writeArchive(t *testing.T, manifest *onlyoffice.HistoryManifest, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func archiveFile(name, content string) onlyoffice.ArchiveFile {
+	sum := sha256.Sum256([]byte(content))
+	return onlyoffice.ArchiveFile{Path: name, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+func TestHistoryArchiveRejectsUnsafeManifest(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		version  int
+		fileType string
+	}{
+		{"FileType", 1, "../../../../../victim.docx"},
+		{"UpperCase", 1, "DOCX"},
+		{"Version", -1, "docx"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewMemory()
+			if _, err := st.Put(context.Background(), "victim.docx", strings.NewReader("original")); err != nil {
+				t.Fatal(err)
+			}
+			client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			archive := writeArchive(t, &onlyoffice.HistoryManifest{
+				Format:        "go-onlyoffice-history",
+				FormatVersion: 1,
+				DocID:         "a.docx",
+				Versions: []onlyoffice.ArchiveVersion{{
+					HistoryVersion: onlyoffice.HistoryVersion{Version: tt.version, FileType: tt.fileType},
+					Files:          map[onlyoffice.HistoryArtifact]onlyoffice.ArchiveFile{onlyoffice.ArtifactDocument: archiveFile("prev", "evil")},
+				}},
+			}, map[string]string{"prev": "evil"})
+
+			if _, err := client.ImportHistory(bytes.NewReader(archive), int64(len(archive)), ""); err == nil {
+				t.Fatal("Expected import to fail")
+			}
+			if got := readObject(t, st, "victim.docx"); got != "original" {
+				t.Errorf("victim.docx = %q", got)
+			}
+		})
+	}
+}
+
+func TestHistoryArchiveLimits(t *testing.T) {
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: storage.NewMemory()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &onlyoffice.HistoryManifest{
+		Format:        "go-onlyoffice-history",
+		FormatVersion: 1,
+		DocID:         "a.docx",
+		Document:      &onlyoffice.ArchiveFile{Path: "document", Size: 4, SHA256: archiveFile("", "tiny").SHA256},
+	}
+
+	// A file larger than the manifest claims is rejected from its header
+	bomb := writeArchive(t, manifest, map[string]string{"document": strings.Repeat("0", 1<<20)})
+	if _, err := client.ImportHistory(bytes.NewReader(bomb), int64(len(bomb)), ""); !errors.Is(err, onlyoffice.ErrChecksumMismatch) {
+		t.Errorf("Expected checksum mismatch, got %v", err)
+	}
+
+	// So is an archive with too many entries
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i <= 100000; i++ {
+		if _, err := zw.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("extra/%d", i), Method: zip.Store}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	crowded := buf.Bytes()
+	if _, err := client.ImportHistory(bytes.NewReader(crowded), int64(len(crowded)), ""); err == nil || !strings.Contains(err.Error(), "entries") {
+		t.Errorf("Expected too many entries, got %v", err)
+	}
+}
+
+// failingHistory fails importing a given version
+type failingHistory struct {
+	*onlyoffice.StorageHistory
+	fail int
+}
+
+func (h failingHistory) ImportVersion(ctx context.Context, docID string, v onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) error {
+	if v.Version == h.fail {
+		return errors.New("disk full")
+	}
+	return h.StorageHistory.ImportVersion(ctx, docID, v, artifacts)
+}
+
+func TestHistoryArchiveImportIsAtomic(t *testing.T) {
+	client, _ := newRestoreClient(t)
+	archive := exportHistory(t, client, "a.docx")
+
+	st := storage.NewMemory()
+	failing, err := onlyoffice.NewClient(&onlyoffice.Config{
+		Storage: st,
+		History: failingHistory{onlyoffice.NewStorageHistory(st), 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := failing.ImportHistory(bytes.NewReader(archive), int64(len(archive)), ""); err == nil {
+		t.Fatal("Expected import to fail")
+	}
+	if n, err := failing.CountVersion("a.docx"); err != nil || n != 0 {
+		t.Errorf("Expected failed import to be removed, got %d versions, %v", n, err)
+	}
+
+	// The import can be retried
+	target, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := target.ImportHistory(bytes.NewReader(archive), int64(len(archive)), ""); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if readObject(t, st, "a.docx") != "v3" {
+		t.Error("Current document not imported")
+	}
+
+	// An existing document is not overwritten
+	other := storage.NewMemory()
+	if _, err := other.Put(context.Background(), "a.docx", strings.NewReader("mine")); err != nil {
+		t.Fatal(err)
+	}
+	target, err = onlyoffice.NewClient(&onlyoffice.Config{Storage: other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := target.ImportHistory(bytes.NewReader(archive), int64(len(archive)), ""); err == nil {
+		t.Error("Expected error importing over an existing document")
+	}
+	if readObject(t, other, "a.docx") != "mine" {
+		t.Error("Existing document overwritten")
+	}
+}
@@ -0,0 +1,180 @@
+package onlyoffice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// AutoSave stores documents for status 2 and 6 callbacks. It downloads the
+// edited file, keeps the previous version together with changes.zip and the
+// history metadata, and issues a new document key. When the Document Server
+// returns a different file type, the file is converted back to the extension
+// of the stored document, so a document keeps one name and one history. Force saves only update
+// the document by default, as the editing session keeps its key, and form
+// submissions are left to OnFormSubmit. Set it on CallbackHandlers to enable
+// it; it runs before OnSave and OnForceSave.
+type AutoSave struct {
+	// Storage receives the documents and their history. Defaults to the
+	// Storage of the client configuration.
+	Storage storage.Storage
+
+	// History records the saved versions. Defaults to Config.History, then
+	// to a StorageHistory on Storage.
+	History HistoryStore
+
+	// Name resolves the storage name of the document a callback belongs to.
+	// Defaults to the "filename" query parameter of the callback request,
+	// then to the callback's Filename.
+	Name func(ctx context.Context, ev *CallbackEvent) (string, error)
+
+	// VersionForceSaves records a history version and issues a new key for
+	// force saves (status 6) as well. The editors keep the old key until
+	// they are reopened with the new one.
+	VersionForceSaves bool
+}
+
+// SaveResult describes a document stored by AutoSave
+type SaveResult struct {
+	Name    string // storage name of the saved document
+	Version int    // history version recorded for the save, 0 if none
+	Key     string // document key for subsequent editing sessions
+}
+
+// save stores the document of a status 2 or 6 callback
+func (a *AutoSave) save(ctx context.Context, c *Client, ev *CallbackEvent) (*SaveResult, error) {
+	cb := ev.Callback
+	st := a.Storage
+	if st == nil {
+		st = c.config.Storage
+	}
+	if st == nil {
+		return nil, errors.New("autosave: no storage configured")
+	}
+	if cb.Url == "" {
+		return nil, errors.New("autosave: empty download url")
+	}
+
+	name, err := a.resolveName(ctx, ev)
+	if err != nil {
+		return nil, fmt.Errorf("autosave: %w", err)
+	}
+
+	res := &SaveResult{Name: name}
+
+	downloadURL := cb.Url
+	ext := strings.ToLower(getExtension(name))
+	if cb.FileType != "" && ext != "" && !strings.EqualFold(cb.FileType, ext) {
+		converted, err := c.convertDocument(ctx, ConvertOptions{
+			DocumentURL: cb.Url,
+			FromExt:     cb.FileType,
+			ToExt:       ext,
+			DocumentKey: cb.Key,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("autosave: convert to %s: %w", ext, err)
+		}
+		if !converted.IsEnd || converted.FileURL == "" {
+			return nil, fmt.Errorf("autosave: conversion to %s failed with error %d", ext, converted.Error)
+		}
+		downloadURL = converted.FileURL
+	}
+
+	// Open the download before touching storage so that a failure leaves the
+	// current document in place and the Document Server retries.
+	body, err := c.openURL(ctx, downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("autosave: download document: %w", err)
+	}
+	defer body.Close()
+
+	history := a.History
+	if history == nil {
+		history = c.config.History
+	}
+	if history == nil {
+		history = NewStorageHistory(st)
+	}
+
+	// Saves and restores of the document must not interleave
+	unlock := documentLocks.lock(name)
+	defer unlock()
+
+	if cb.Status == models.StatusForceSave && !a.VersionForceSaves {
+		if _, err := st.Put(ctx, name, body); err != nil {
+			return nil, fmt.Errorf("autosave: store document: %w", err)
+		}
+		res.Key = cb.Key
+		return res, nil
+	}
+
+	// The current document becomes the file of the new version, so the
+	// version is recorded before the document is replaced; a retry of the
+	// same save reuses it.
+	v, err := a.recordOnce(ctx, c, history, st, name, cb)
+	if err != nil {
+		return nil, fmt.Errorf("autosave: %w", err)
+	}
+	res.Version = v.Version
+
+	if _, err := st.Put(ctx, name, body); err != nil {
+		return nil, fmt.Errorf("autosave: store document: %w", err)
+	}
+
+	if res.Key, err = c.GenerateFileHash(name); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// recordOnce records the version of a save unless the latest version
+// already belongs to it, which happens when the Document Server retries a
+// callback whose document could not be stored. A save is identified by its
+// key and its creation time, from the history or lastsave; saves reporting
+// neither are always recorded.
+func (a *AutoSave) recordOnce(ctx context.Context, c *Client, history HistoryStore, st storage.Storage, name string, cb *models.Callback) (*HistoryVersion, error) {
+	versions, err := history.Versions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(versions); n > 0 {
+		last := versions[n-1]
+		if created, ok := callbackCreated(cb); ok && last.Key == cb.Key && last.Created.Equal(created) {
+			return &last, nil
+		}
+	}
+	return c.recordVersion(ctx, history, st, name, cb)
+}
+
+// resolveName returns the storage name of the document
+func (a *AutoSave) resolveName(ctx context.Context, ev *CallbackEvent) (string, error) {
+	var name string
+	if a.Name != nil {
+		var err error
+		if name, err = a.Name(ctx, ev); err != nil {
+			return "", err
+		}
+	} else {
+		if ev.Request != nil {
+			name = ev.Request.URL.Query().Get("filename")
+		}
+		if name == "" {
+			name = ev.Callback.Filename
+		}
+	}
+
+	if name == "" {
+		return "", errors.New("cannot resolve document name")
+	}
+	return storage.CleanName(name)
+}
+
+// isSaveStatus reports whether AutoSave handles the callback status
+func isSaveStatus(status models.CallbackStatus) bool {
+	return status == models.StatusMustSave || status == models.StatusForceSave
+}
@@ -0,0 +1,215 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func TestAutoSave(t *testing.T) {
+	var files *httptest.Server
+	files = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/out.docx":
+			w.Write([]byte("new content"))
+		case "/out.odt":
+			w.Write([]byte("odt content"))
+		case "/changes.zip":
+			w.Write([]byte("changes"))
+		case "/ConvertService.ashx":
+			var req struct {
+				URL        string `json:"url"`
+				OutputType string `json:"outputtype"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.URL != files.URL+"/out.odt" || req.OutputType != "docx" {
+				http.Error(w, "unexpected conversion", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"fileUrl": files.URL + "/converted.docx", "endConvert": true})
+		case "/converted.docx":
+			w.Write([]byte("converted content"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer files.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.docx"), []byte("old content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{DocumentServerURL: files.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved *onlyoffice.SaveResult
+	record := func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+		saved = ev.Saved
+		return nil
+	}
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		AutoSave:         &onlyoffice.AutoSave{Storage: storage.NewFS(dir)},
+		OnSaveEvent:      record,
+		OnForceSaveEvent: record,
+	})
+
+	rec := postCallbackTo(h, "/callback?filename=doc.docx", fmt.Sprintf(`{
+		"status": 2,
+		"key": "k1",
+		"url": "%s/out.docx",
+		"changesurl": "%s/changes.zip",
+		"filetype": "docx",
+		"history": {"serverVersion": "7.3.0", "changes": [{"created": "2026-01-21 15:52:27", "user": {"id": "u1"}}]}
+	}`, files.URL, files.URL))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	if saved == nil || saved.Name != "doc.docx" || saved.Version != 1 || saved.Key == "" || saved.Key == "k1" {
+		t.Fatalf("Unexpected save result %+v", saved)
+	}
+	assertFile(t, filepath.Join(dir, "doc.docx"), "new content")
+	assertFile(t, filepath.Join(dir, ".history", "doc.docx", "1", "prev.docx"), "old content")
+	assertFile(t, filepath.Join(dir, ".history", "doc.docx", "1", "diff.zip"), "changes")
+	if _, err := os.Stat(filepath.Join(dir, ".history", "doc.docx", "1", "changes.json")); err != nil {
+		t.Errorf("Expected changes.json: %v", err)
+	}
+
+	// A different file type is converted back, so the document keeps its
+	// name and history. Force saves keep the key and record no version.
+	rec = postCallbackTo(h, "/callback?filename=doc.docx", fmt.Sprintf(`{
+		"status": 6, "key": "k2", "url": "%s/out.odt", "filetype": "odt"
+	}`, files.URL))
+	if rec.Code != http.StatusOK || saved.Name != "doc.docx" || saved.Version != 0 || saved.Key != "k2" {
+		t.Errorf("Unexpected result %d %+v", rec.Code, saved)
+	}
+	assertFile(t, filepath.Join(dir, "doc.docx"), "converted content")
+	if _, err := os.Stat(filepath.Join(dir, "doc.odt")); !os.IsNotExist(err) {
+		t.Errorf("Expected no document under the new extension, got %v", err)
+	}
+
+	// A failed download is reported so that the Document Server retries
+	rec = postCallbackTo(h, "/callback?filename=doc.docx", fmt.Sprintf(`{
+		"status": 2, "key": "k3", "url": "%s/missing.docx"
+	}`, files.URL))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", rec.Code)
+	}
+	assertFile(t, filepath.Join(dir, "doc.docx"), "converted content")
+}
+
+func assertFile(t *testing.T, name, want string) {
+	t.Helper()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Errorf("Failed to read %s: %v", name, err)
+		return
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", name, data, want)
+	}
+}
+
+// failingStorage fails the first Put of a name
+type failingStorage struct {
+	storage.Storage
+	name   string
+	failed bool
+}
+
+func (s *failingStorage) Put(ctx context.Context, name string, r io.Reader) (*storage.ObjectInfo, error) {
+	if name == s.name && !s.failed {
+		s.failed = true
+		return nil, errors.New("disk full")
+	}
+	return s.Storage.Put(ctx, name, r)
+}
+
+func TestAutoSaveRetryAndForceSave(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/")))
+	}))
+	defer files.Close()
+
+	mem := storage.NewMemory()
+	if _, err := mem.Put(context.Background(), "doc.docx", strings.NewReader("v0")); err != nil {
+		t.Fatal(err)
+	}
+	st := &failingStorage{Storage: mem, name: "doc.docx"}
+	auto := &onlyoffice.AutoSave{Storage: st}
+	client := newTestClient(t)
+	var saved *onlyoffice.SaveResult
+	record := func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+		saved = ev.Saved
+		return nil
+	}
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{AutoSave: auto, OnSaveEvent: record, OnForceSaveEvent: record})
+	history := onlyoffice.NewStorageHistory(mem)
+
+	save := func(status int, key, content, created string) int {
+		t.Helper()
+		return postCallbackTo(h, "/callback?filename=doc.docx", fmt.Sprintf(`{
+			"status": %d, "key": "%s", "url": "%s/%s",
+			"history": {"created": "%s"}
+		}`, status, key, files.URL, content, created)).Code
+	}
+
+	// The first attempt fails to store the document; its retry must not
+	// record a second version
+	if code := save(2, "k1", "v1", "2026-01-21 10:00:00"); code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", code)
+	}
+	if code := save(2, "k1", "v1", "2026-01-21 10:00:00"); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	versions, err := history.Versions(context.Background(), "doc.docx")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("Expected 1 version, got %d (%v)", len(versions), err)
+	}
+	if saved.Version != 1 || readObject(t, mem, "doc.docx") != "v1" {
+		t.Errorf("Unexpected result %+v", saved)
+	}
+
+	// Force saves are versioned on request
+	auto.VersionForceSaves = true
+	if code := save(6, saved.Key, "v2", "2026-01-21 11:00:00"); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if saved.Version != 2 || readObject(t, mem, "doc.docx") != "v2" {
+		t.Errorf("Unexpected force save result %+v", saved)
+	}
+
+	// Without a history time the retry is recognized by its lastsave
+	st.failed = false
+	saveLast := func() int {
+		t.Helper()
+		return postCallbackTo(h, "/callback?filename=doc.docx", fmt.Sprintf(`{
+			"status": 2, "key": "%s", "url": "%s/v3",
+			"lastsave": "2026-01-21T12:00:00.000Z"
+		}`, saved.Key, files.URL)).Code
+	}
+	if code := saveLast(); code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", code)
+	}
+	if code := saveLast(); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	versions, err = history.Versions(context.Background(), "doc.docx")
+	if err != nil || len(versions) != 3 {
+		t.Errorf("Expected 3 versions, got %d (%v)", len(versions), err)
+	}
+}
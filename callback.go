@@ -3,10 +3,15 @@ package onlyoffice
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/royalrick/go-onlyoffice/models"
 )
 
+// ParseCallback decodes a callback request body. With JWT enabled the
+// callback is decoded from the verified claims of the token in the
+// Authorization header, or in the body when the header is empty, so that no
+// field is taken from the unsigned body.
 func (c *Client) ParseCallback(jsonData []byte, tokenHeader string) (*models.Callback, error) {
 	var callback models.Callback
 
@@ -15,12 +20,12 @@ func (c *Client) ParseCallback(jsonData []byte, tokenHeader string) (*models.Cal
 	}
 
 	if c.config.JWTEnabled {
+		tokenHeader = strings.TrimPrefix(tokenHeader, "Bearer ")
 		if tokenHeader == "" {
-			return nil, errors.New("missing token")
+			tokenHeader = callback.Token
 		}
-
-		if len(tokenHeader) > 7 && tokenHeader[:7] == "Bearer " {
-			tokenHeader = tokenHeader[7:]
+		if tokenHeader == "" {
+			return nil, errors.New("missing token")
 		}
 
 		claims, err := c.ParseToken(tokenHeader)
@@ -28,16 +33,17 @@ func (c *Client) ParseCallback(jsonData []byte, tokenHeader string) (*models.Cal
 			return nil, err
 		}
 
-		if claims["status"] != nil {
-			if status, ok := claims["status"].(float64); ok {
-				callback.Status = int(status)
-			}
+		// Tokens sent in the header wrap the callback in a "payload" object
+		if p, ok := claims["payload"].(map[string]any); ok {
+			claims = p
 		}
-
-		if claims["key"] != nil {
-			if key, ok := claims["key"].(string); ok {
-				callback.Key = key
-			}
+		data, err := json.Marshal(claims)
+		if err != nil {
+			return nil, err
+		}
+		callback = models.Callback{}
+		if err := json.Unmarshal(data, &callback); err != nil {
+			return nil, err
 		}
 	}
 
@@ -48,9 +54,9 @@ func (c *Client) ParseCallback(jsonData []byte, tokenHeader string) (*models.Cal
 
 func (c *Client) ValidateCallback(callback *models.Callback) error {
 	switch callback.Status {
-	case 2, 6:
+	case models.StatusMustSave, models.StatusForceSave:
 		return nil
-	case 7:
+	case models.StatusCorrupted:
 		return errors.New("document is corrupted")
 	default:
 		return errors.New("unknown callback status")
@@ -11,13 +11,33 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
 )
 
 type Config struct {
+	// DocumentServerURL is the Document Server address as reached by browsers.
 	DocumentServerURL string
-	JWTSecret         string
-	JWTEnabled        bool
+	// DocumentServerInternalURL is the Document Server address as reached by
+	// this backend. Defaults to DocumentServerURL.
+	DocumentServerInternalURL string
+	// StorageURL is the public base address of our own application and file
+	// storage, as used in the URLs passed to the SDK.
+	StorageURL string
+	// StorageInternalURL is the address the Document Server uses to reach our
+	// storage. Defaults to StorageURL.
+	StorageInternalURL string
+	JWTSecret          string
+	JWTEnabled         bool
+	// FileSigningSecret signs URLs served by FileHandler. Defaults to
+	// JWTSecret.
+	FileSigningSecret string
 	HTTPClient        *http.Client
+	// Storage holds documents and their history. It is the default for
+	// AutoSave, Quarantine and the history functions.
+	Storage storage.Storage
+	// History keeps document version history. Defaults to a StorageHistory
+	// on Storage.
+	History HistoryStore
 }
 
 type Client struct {
@@ -89,11 +109,16 @@ func (c *Client) BuildEditorConfig(params models.EditorParams, fileURL string) (
 		ext = ext[idx+1:]
 	}
 
-	fileKey, err := c.GenerateFileHash(params.Filename)
-	if err != nil {
-		return nil, err
+	fileKey := params.Key
+	if fileKey == "" {
+		var err error
+		if fileKey, err = c.GenerateFileHash(params.Filename); err != nil {
+			return nil, err
+		}
 	}
 
+	docURL := c.toInternalStorageURL(fileURL)
+
 	cfg := &models.Config{
 		Type:         "desktop",
 		DocumentType: c.getDocumentType(ext),
@@ -101,7 +126,7 @@ func (c *Client) BuildEditorConfig(params models.EditorParams, fileURL string) (
 			FileType: ext,
 			Key:      fileKey,
 			Title:    params.Filename,
-			Url:      fileURL,
+			Url:      docURL,
 			Info: models.MetaInfo{
 				Author:  params.UserId,
 				Created: time.Now().Format("2006-01-02 15:04:05"),
@@ -123,7 +148,7 @@ func (c *Client) BuildEditorConfig(params models.EditorParams, fileURL string) (
 				Name:  params.UserName,
 				Email: params.UserEmail,
 			},
-			CallbackUrl: params.CallbackUrl,
+			CallbackUrl: c.toInternalStorageURL(params.CallbackUrl),
 			Lang:        params.Language,
 			Mode:        params.Mode,
 			Customization: models.Customization{
@@ -137,7 +162,7 @@ func (c *Client) BuildEditorConfig(params models.EditorParams, fileURL string) (
 		claims := jwt.MapClaims{
 			"document": map[string]any{
 				"key":      fileKey,
-				"url":      fileURL,
+				"url":      docURL,
 				"fileType": ext,
 			},
 			"editorConfig": map[string]any{
@@ -1,8 +1,10 @@
 package onlyoffice_test
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/royalrick/go-onlyoffice"
 	"github.com/royalrick/go-onlyoffice/models"
 )
@@ -149,6 +151,58 @@ func TestParseCallback(t *testing.T) {
 	}
 }
 
+func TestParseCallbackJWT(t *testing.T) {
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{
+		DocumentServerURL: "https://example.com",
+		JWTSecret:         "secret",
+		JWTEnabled:        true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	signed := map[string]any{
+		"status":   2,
+		"key":      "test-key",
+		"url":      "https://example.com/signed.docx",
+		"filetype": "docx",
+		"lastsave": "2026-01-21T15:52:27.000Z",
+	}
+	header, err := client.CreateToken(jwt.MapClaims{"payload": signed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := client.CreateToken(jwt.MapClaims(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields of the unsigned body are ignored
+	forged := `{"status": 6, "key": "test-key", "url": "https://evil.com/a.docx",
+		"changesurl": "https://evil.com/c.zip", "filetype": "exe", "forcesavetype": 3,
+		"formsdataurl": "https://evil.com/f.json", "token": "%s"}`
+	for name, tt := range map[string]struct{ body, header string }{
+		"Header": {fmt.Sprintf(forged, ""), "Bearer " + header},
+		"Body":   {fmt.Sprintf(forged, body), ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			callback, err := client.ParseCallback([]byte(tt.body), tt.header)
+			if err != nil {
+				t.Fatalf("Failed to parse callback: %v", err)
+			}
+			if callback.Status != models.StatusMustSave || callback.Url != "https://example.com/signed.docx" ||
+				callback.ChangesUrl != "" || callback.FileType != "docx" || callback.ForceSaveType != 0 ||
+				callback.FormsDataUrl != "" || callback.LastSave != "2026-01-21T15:52:27.000Z" {
+				t.Errorf("Unexpected callback %+v", callback)
+			}
+		})
+	}
+
+	if _, err := client.ParseCallback([]byte(fmt.Sprintf(forged, "")), ""); err == nil {
+		t.Error("Expected an error without a token")
+	}
+}
+
 func TestValidateCallback(t *testing.T) {
 	config := &onlyoffice.Config{
 		DocumentServerURL: "https://example.com",
@@ -161,7 +215,7 @@ func TestValidateCallback(t *testing.T) {
 
 	tests := []struct {
 		name    string
-		status  int
+		status  models.CallbackStatus
 		wantErr bool
 	}{
 		{"Edited", 2, false},
@@ -180,3 +234,49 @@ func TestValidateCallback(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCallbackTypedFields(t *testing.T) {
+	config := &onlyoffice.Config{
+		DocumentServerURL: "https://example.com",
+	}
+
+	client, err := onlyoffice.NewClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	jsonData := []byte(`{
+		"status": 6,
+		"key": "test-key",
+		"url": "https://example.com/file.docx",
+		"actions": [{"type": 2, "userid": "user1"}],
+		"forcesavetype": 3,
+		"filetype": "docx",
+		"lastsave": "2026-01-21T15:52:27.000Z",
+		"notmodified": true,
+		"userdata": "sample",
+		"formsdataurl": "https://example.com/forms.json"
+	}`)
+
+	callback, err := client.ParseCallback(jsonData, "")
+	if err != nil {
+		t.Fatalf("Failed to parse callback: %v", err)
+	}
+
+	if callback.Status != models.StatusForceSave || callback.Status.String() != "force-save" {
+		t.Errorf("Unexpected status %v", callback.Status)
+	}
+	if callback.ForceSaveType != models.ForceSaveForm || callback.ForceSaveType.String() != "form" {
+		t.Errorf("Unexpected forcesave type %v", callback.ForceSaveType)
+	}
+	if len(callback.Actions) != 1 || callback.Actions[0].Type != models.ActionForceSave {
+		t.Errorf("Unexpected actions %+v", callback.Actions)
+	}
+	if callback.FileType != "docx" || callback.LastSave == "" || !callback.NotModified ||
+		callback.UserData != "sample" || callback.FormsDataUrl != "https://example.com/forms.json" {
+		t.Errorf("Unexpected callback fields %+v", callback)
+	}
+	if got := models.CallbackStatus(5).String(); got != "status(5)" {
+		t.Errorf("Unexpected unknown status string %q", got)
+	}
+}
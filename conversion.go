@@ -1,6 +1,7 @@
 package onlyoffice
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -29,14 +30,19 @@ type ConvertResult struct {
 }
 
 func (c *Client) ConvertDocument(opts ConvertOptions) (*ConvertResult, error) {
+	return c.convertDocument(context.Background(), opts)
+}
+
+// convertDocument implements ConvertDocument
+func (c *Client) convertDocument(ctx context.Context, opts ConvertOptions) (*ConvertResult, error) {
 	if opts.FromExt == "" {
 		opts.FromExt = getExtension(opts.DocumentURL)
 	}
 
-	convertURL := fmt.Sprintf("%s/ConvertService.ashx", c.config.DocumentServerURL)
+	convertURL := fmt.Sprintf("%s/ConvertService.ashx", c.documentServerInternalURL())
 
 	payload := map[string]any{
-		"url":         opts.DocumentURL,
+		"url":         c.toInternalStorageURL(opts.DocumentURL),
 		"outputtype":  opts.ToExt,
 		"filetype":    opts.FromExt,
 		"title":       opts.Title,
@@ -60,7 +66,7 @@ func (c *Client) ConvertDocument(opts ConvertOptions) (*ConvertResult, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", convertURL, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequestWithContext(ctx, "POST", convertURL, strings.NewReader(string(jsonData)))
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +129,20 @@ func getExtension(filename string) string {
 }
 
 func (c *Client) DownloadFile(fileURL string) ([]byte, error) {
-	req, err := http.NewRequest("GET", fileURL, nil)
+	body, err := c.openURL(context.Background(), fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// openURL starts a download and returns the response body. URLs issued by
+// the Document Server are rewritten to its internal address here, so that
+// callbacks keep the URLs as the server sent them.
+func (c *Client) openURL(ctx context.Context, fileURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.toInternalDocumentServerURL(fileURL), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -132,11 +151,11 @@ func (c *Client) DownloadFile(fileURL string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
 	}
 
-	return io.ReadAll(resp.Body)
+	return resp.Body, nil
 }
@@ -0,0 +1,283 @@
+package onlyoffice
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+// DedupStore records callbacks whose processing has completed so that
+// retried deliveries can be acknowledged without running handlers again
+type DedupStore interface {
+	// Seen reports whether processing for key has already completed
+	Seen(ctx context.Context, key string) (bool, error)
+	// Done records that processing for key has completed
+	Done(ctx context.Context, key string) error
+}
+
+// IdempotencyKey returns the key identifying a callback delivery. Retries of
+// the same save share the key; it is derived from the document key, status
+// and last save time. The download url is left out, as the Document Server
+// may issue a new one for each retry.
+func IdempotencyKey(cb *models.Callback) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%s", cb.Key, cb.Status, cb.LastSave)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isDedupStatus reports whether callbacks with status are deduplicated.
+// Editing and close notifications carry no work to repeat.
+func isDedupStatus(status models.CallbackStatus) bool {
+	switch status {
+	case models.StatusMustSave, models.StatusSaveError, models.StatusForceSave, models.StatusCorrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// MemoryDedupStore is an in-process DedupStore
+type MemoryDedupStore struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	keys  map[string]time.Time
+	swept time.Time // last removal of expired keys
+}
+
+// NewMemoryDedupStore returns a DedupStore that forgets keys after ttl. A
+// zero ttl keeps keys forever.
+func NewMemoryDedupStore(ttl time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{ttl: ttl, keys: make(map[string]time.Time), swept: time.Now()}
+}
+
+// Seen implements DedupStore
+func (s *MemoryDedupStore) Seen(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	done, ok := s.keys[key]
+	if ok && s.expired(done) {
+		delete(s.keys, key)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// Done implements DedupStore. Expired keys are removed once per ttl, so keys
+// that are never looked up again do not accumulate.
+func (s *MemoryDedupStore) Done(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = time.Now()
+	if s.ttl > 0 && time.Since(s.swept) > s.ttl {
+		s.sweep()
+	}
+	return nil
+}
+
+// sweep removes the expired keys
+func (s *MemoryDedupStore) sweep() {
+	for key, done := range s.keys {
+		if s.expired(done) {
+			delete(s.keys, key)
+		}
+	}
+	s.swept = time.Now()
+}
+
+// size returns the number of remembered keys, including expired keys that
+// have not been removed yet
+func (s *MemoryDedupStore) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+func (s *MemoryDedupStore) expired(done time.Time) bool {
+	return s.ttl > 0 && time.Since(done) > s.ttl
+}
+
+// minDedupCompaction is the number of records a dedup file may hold beyond
+// twice its live keys before it is compacted
+const minDedupCompaction = 1024
+
+// FileDedupStore is a DedupStore persisted to an append-only file, so that
+// completed callbacks survive restarts. The file is rewritten with the live
+// keys when it is opened and whenever expired or repeated records make up
+// most of it.
+type FileDedupStore struct {
+	mem     *MemoryDedupStore
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	records int // lines in the file
+}
+
+// NewFileDedupStore opens or creates the dedup file at path. Keys older than
+// ttl are ignored and dropped from the file; a zero ttl keeps keys forever.
+func NewFileDedupStore(path string, ttl time.Duration) (*FileDedupStore, error) {
+	mem := NewMemoryDedupStore(ttl)
+
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, ts, ok := strings.Cut(scanner.Text(), " ")
+			if !ok {
+				continue
+			}
+			sec, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				continue
+			}
+			if done := time.Unix(sec, 0); !mem.expired(done) {
+				mem.keys[key] = done
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	s := &FileDedupStore{mem: mem, path: path}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Seen implements DedupStore
+func (s *FileDedupStore) Seen(ctx context.Context, key string) (bool, error) {
+	return s.mem.Seen(ctx, key)
+}
+
+// Done implements DedupStore. The record is synced to disk before returning.
+func (s *FileDedupStore) Done(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.file, "%s %d\n", key, time.Now().Unix()); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.records++
+	s.mem.Done(ctx, key)
+
+	if s.records > 2*s.mem.size()+minDedupCompaction {
+		return s.compact()
+	}
+	return nil
+}
+
+// Compact rewrites the dedup file with the keys that have not expired
+func (s *FileDedupStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+// compact rewrites the file through a temporary file and reopens it for
+// appending
+func (s *FileDedupStore) compact() error {
+	s.mem.mu.Lock()
+	s.mem.sweep()
+	keys := make(map[string]time.Time, len(s.mem.keys))
+	for key, done := range s.mem.keys {
+		keys[key] = done
+	}
+	s.mem.mu.Unlock()
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for key, done := range keys {
+		fmt.Fprintf(w, "%s %d\n", key, done.Unix())
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	s.records = len(keys)
+	return nil
+}
+
+// Close closes the underlying file
+func (s *FileDedupStore) Close() error {
+	return s.file.Close()
+}
+
+// keyedMutex serializes work per key
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the mutex for key and returns its release function
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,153 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+func TestCallbackHandlerDedup(t *testing.T) {
+	client := newTestClient(t)
+
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	store, err := onlyoffice.NewFileDedupStore(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open dedup store: %v", err)
+	}
+	defer store.Close()
+
+	saves, fail := 0, true
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnSave: func(cb *models.Callback) error {
+			saves++
+			if fail {
+				fail = false
+				return errors.New("temporary failure")
+			}
+			return nil
+		},
+	}, onlyoffice.WithDedupStore(store))
+
+	body := `{"status": 2, "key": "k1", "url": "https://example.com/out.docx", "lastsave": "2026-01-21T15:52:27.000Z"}`
+	codes := []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK}
+	for i, want := range codes {
+		if rec := postCallback(h, body); rec.Code != want {
+			t.Errorf("Delivery %d: expected %d, got %d", i, want, rec.Code)
+		}
+	}
+	if saves != 2 {
+		t.Errorf("Expected OnSave to run twice (failure and retry), ran %d times", saves)
+	}
+
+	// A later save of the same document is processed
+	postCallback(h, `{"status": 2, "key": "k1", "url": "https://example.com/out.docx", "lastsave": "2026-01-21T16:00:00.000Z"}`)
+	if saves != 3 {
+		t.Errorf("Expected a new save to run, ran %d times", saves)
+	}
+
+	// Completed keys survive reopening the file
+	reopened, err := onlyoffice.NewFileDedupStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	// Retries with a fresh download url share the key
+	cb := &models.Callback{Status: models.StatusMustSave, Key: "k1", Url: "https://example.com/retry.docx", LastSave: "2026-01-21T15:52:27.000Z"}
+	if seen, _ := reopened.Seen(context.Background(), onlyoffice.IdempotencyKey(cb)); !seen {
+		t.Error("Expected key to be persisted")
+	}
+}
+
+// brokenDedupStore fails to record completed callbacks
+type brokenDedupStore struct {
+	onlyoffice.DedupStore
+}
+
+func (brokenDedupStore) Done(ctx context.Context, key string) error {
+	return errors.New("disk full")
+}
+
+func TestCallbackHandlerDedupReportsDoneErrors(t *testing.T) {
+	client := newTestClient(t)
+
+	var reported error
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{},
+		onlyoffice.WithDedupStore(brokenDedupStore{onlyoffice.NewMemoryDedupStore(0)}),
+		onlyoffice.WithErrorReporter(func(r *http.Request, status int, err error) {
+			reported = err
+		}))
+
+	// The callback still succeeds, as its work is done
+	if rec := postCallback(h, `{"status": 2, "key": "k1", "lastsave": "2026-01-21T15:52:27.000Z"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+	if reported == nil || !strings.Contains(reported.Error(), "disk full") {
+		t.Errorf("Expected the dedup error to be reported, got %v", reported)
+	}
+}
+
+func TestMemoryDedupStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	store := onlyoffice.NewMemoryDedupStore(time.Millisecond)
+	store.Done(ctx, "k")
+	time.Sleep(5 * time.Millisecond)
+	if seen, _ := store.Seen(ctx, "k"); seen {
+		t.Error("Expected expired key to be forgotten")
+	}
+
+	store = onlyoffice.NewMemoryDedupStore(0)
+	store.Done(ctx, "k")
+	if seen, _ := store.Seen(ctx, "k"); !seen {
+		t.Error("Expected key to be remembered")
+	}
+}
+
+func TestFileDedupStoreCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	lines := func() int {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(data), "\n")
+	}
+
+	// Repeated records are compacted while the store is in use
+	store, err := onlyoffice.NewFileDedupStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1100; i++ {
+		if err := store.Done(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := lines(); n > 100 {
+		t.Errorf("Expected the file to be compacted, got %d lines", n)
+	}
+	store.Close()
+
+	// Expired keys are dropped from the file when it is opened
+	time.Sleep(5 * time.Millisecond)
+	store, err = onlyoffice.NewFileDedupStore(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if n := lines(); n != 0 {
+		t.Errorf("Expected expired keys to be dropped, got %d lines", n)
+	}
+	if seen, _ := store.Seen(ctx, "k"); seen {
+		t.Error("Expected expired key to be forgotten")
+	}
+}
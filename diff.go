@@ -0,0 +1,410 @@
+package onlyoffice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion refers to the current document in DiffVersions
+const CurrentVersion = 0
+
+// DiffOptions configures DiffVersions
+type DiffOptions struct {
+	// ConvertURL returns a URL the Document Server can download the file of
+	// a version from, CurrentVersion being the current document. When set,
+	// file types ExtractText does not read are converted to txt through
+	// ConvertDocument.
+	ConvertURL func(docID string, version int) (string, error)
+}
+
+// DiffOp is the operation of a DiffLine
+type DiffOp string
+
+const (
+	// DiffEqual is a paragraph found in both versions
+	DiffEqual DiffOp = "equal"
+	// DiffInsert is a paragraph added in the newer version
+	DiffInsert DiffOp = "insert"
+	// DiffDelete is a paragraph removed from the older version
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffLine is a paragraph of a VersionDiff
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+	// OldLine and NewLine are 1-based line numbers in the compared texts,
+	// 0 when the line does not exist on that side
+	OldLine int `json:"oldLine,omitempty"`
+	NewLine int `json:"newLine,omitempty"`
+}
+
+// VersionDiff is the paragraph diff between two versions of a document
+type VersionDiff struct {
+	DocID   string     `json:"docId"`
+	From    int        `json:"from"`
+	To      int        `json:"to"`
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Lines   []DiffLine `json:"lines"`
+}
+
+// DiffVersions compares the text of two versions of a document, paragraph
+// by paragraph. A version is read from its stored file; CurrentVersion
+// compares against the current document in Config.Storage. Text is
+// extracted with ExtractText, or converted by the Document Server when
+// opts.ConvertURL is set and the file type is not supported locally.
+func (c *Client) DiffVersions(docID string, a, b int, opts DiffOptions) (*VersionDiff, error) {
+	ctx := context.Background()
+	oldText, err := c.versionText(ctx, docID, a, opts)
+	if err != nil {
+		return nil, fmt.Errorf("diff: version %d: %w", a, err)
+	}
+	newText, err := c.versionText(ctx, docID, b, opts)
+	if err != nil {
+		return nil, fmt.Errorf("diff: version %d: %w", b, err)
+	}
+
+	d := &VersionDiff{DocID: docID, From: a, To: b}
+	d.Lines = diffLines(splitLines(oldText), splitLines(newText))
+	for _, l := range d.Lines {
+		switch l.Op {
+		case DiffInsert:
+			d.Added++
+		case DiffDelete:
+			d.Removed++
+		}
+	}
+	return d, nil
+}
+
+// versionText returns the text of a version or the current document
+func (c *Client) versionText(ctx context.Context, docID string, version int, opts DiffOptions) (string, error) {
+	var (
+		rc       io.ReadCloser
+		fileType = strings.TrimPrefix(path.Ext(docID), ".")
+	)
+	if version == CurrentVersion {
+		st := c.config.Storage
+		if st == nil {
+			return "", errors.New("no storage configured")
+		}
+		r, _, err := st.Get(ctx, docID)
+		if err != nil {
+			return "", err
+		}
+		rc = r
+	} else {
+		store, err := c.historyStore()
+		if err != nil {
+			return "", err
+		}
+		v, err := store.Version(ctx, docID, version)
+		if err != nil {
+			return "", err
+		}
+		if v.FileType != "" {
+			fileType = v.FileType
+		}
+		r, _, err := store.OpenArtifact(ctx, docID, version, ArtifactDocument)
+		if err != nil {
+			return "", err
+		}
+		rc = r
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+
+	text, err := ExtractText(data, fileType)
+	if !errors.Is(err, ErrTextUnsupported) || opts.ConvertURL == nil {
+		return text, err
+	}
+
+	// The conversion key identifies the content, so the Document Server can
+	// reuse earlier conversions of the same file
+	sum := sha256.Sum256(data)
+	key := "diff_" + hex.EncodeToString(sum[:16])
+	url, err := opts.ConvertURL(docID, version)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.ConvertDocument(ConvertOptions{
+		DocumentURL: url,
+		FromExt:     fileType,
+		ToExt:       "txt",
+		DocumentKey: key,
+		Title:       path.Base(docID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.Error != 0 {
+		return "", fmt.Errorf("conversion to txt failed with error %d", res.Error)
+	}
+	if !res.IsEnd || res.FileURL == "" {
+		return "", errors.New("conversion to txt did not finish")
+	}
+	converted, err := c.DownloadFile(res.FileURL)
+	if err != nil {
+		return "", err
+	}
+	// Converted text files start with a byte order mark
+	return strings.ReplaceAll(strings.TrimPrefix(string(converted), "\ufeff"), "\r\n", "\n"), nil
+}
+
+// splitLines splits text into lines, ignoring a trailing newline
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// maxDiffEdits bounds the number of inserted and deleted lines diffLines
+// searches for. Regions that differ more are reported as deleted and
+// inserted as a whole, which keeps memory at O(maxDiffEdits²) whatever the
+// size of the documents.
+const maxDiffEdits = 2048
+
+// diffLines computes a line diff with Myers' O(ND) algorithm. The common
+// prefix and suffix are matched first, so the search only covers the
+// changed region.
+func diffLines(a, b []string) []DiffLine {
+	lines := []DiffLine{}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[prefix], OldLine: prefix + 1, NewLine: prefix + 1})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	i, j := 0, 0
+	for _, op := range diffOps(ma, mb) {
+		switch op {
+		case DiffEqual:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: ma[i], OldLine: prefix + i + 1, NewLine: prefix + j + 1})
+			i++
+			j++
+		case DiffInsert:
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: mb[j], NewLine: prefix + j + 1})
+			j++
+		case DiffDelete:
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: ma[i], OldLine: prefix + i + 1})
+			i++
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		oi, nj := len(a)-suffix+k, len(b)-suffix+k
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[oi], OldLine: oi + 1, NewLine: nj + 1})
+	}
+	return lines
+}
+
+// diffOps returns the shortest edit script turning a into b, with deletions
+// before insertions at each change. Beyond maxDiffEdits it deletes all of a
+// and inserts all of b.
+func diffOps(a, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+
+	// v[off+k] is the furthest x reached on diagonal k = x - y; trace[d]
+	// keeps the diagonals -d…d after d edits for the backtrack
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	found := false
+search:
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break search
+			}
+		}
+		trace = append(trace, slices.Clone(v[off-d:off+d+1]))
+	}
+
+	if !found {
+		ops := make([]DiffOp, 0, n+m)
+		for range a {
+			ops = append(ops, DiffDelete)
+		}
+		for range b {
+			ops = append(ops, DiffInsert)
+		}
+		return ops
+	}
+
+	// Walk back from the end, collecting the operations in reverse
+	var ops []DiffOp
+	x, y := n, m
+	for d := len(trace); d > 0; d-- {
+		prev := trace[d-1] // diagonals -(d-1)…d-1, indexed from 0
+		k := x - y
+		var pk int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := prev[pk+d-1]
+		py := px - pk
+		for x > px && y > py {
+			ops = append(ops, DiffEqual)
+			x--
+			y--
+		}
+		if x == px {
+			ops = append(ops, DiffInsert)
+		} else {
+			ops = append(ops, DiffDelete)
+		}
+		x, y = px, py
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, DiffEqual)
+		x--
+		y--
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// versionLabel names a version in rendered diffs
+func versionLabel(version int) string {
+	if version == CurrentVersion {
+		return "current"
+	}
+	return "version " + strconv.Itoa(version)
+}
+
+// Unified renders the diff in unified format with the given number of
+// unchanged context lines around each change
+func (d *VersionDiff) Unified(context int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s (%s)\n+++ %s (%s)\n", d.DocID, versionLabel(d.From), d.DocID, versionLabel(d.To))
+
+	for _, h := range d.hunks(context) {
+		lines := d.Lines[h[0]:h[1]]
+		oldStart, oldCount, newStart, newCount := 0, 0, 0, 0
+		for _, l := range lines {
+			if l.OldLine > 0 {
+				if oldCount == 0 {
+					oldStart = l.OldLine
+				}
+				oldCount++
+			}
+			if l.NewLine > 0 {
+				if newCount == 0 {
+					newStart = l.NewLine
+				}
+				newCount++
+			}
+		}
+		// An empty side starts at the line before the hunk
+		if oldCount == 0 {
+			oldStart = hunkAnchor(d.Lines[:h[0]], func(l DiffLine) int { return l.OldLine })
+		}
+		if newCount == 0 {
+			newStart = hunkAnchor(d.Lines[:h[0]], func(l DiffLine) int { return l.NewLine })
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+		for _, l := range lines {
+			switch l.Op {
+			case DiffEqual:
+				b.WriteByte(' ')
+			case DiffInsert:
+				b.WriteByte('+')
+			case DiffDelete:
+				b.WriteByte('-')
+			}
+			b.WriteString(l.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// hunks returns the [start, end) ranges of Lines to render, each change
+// with up to context unchanged lines around it
+func (d *VersionDiff) hunks(context int) [][2]int {
+	if context < 0 {
+		context = 0
+	}
+	var hunks [][2]int
+	for i, l := range d.Lines {
+		if l.Op == DiffEqual {
+			continue
+		}
+		start := max(i-context, 0)
+		end := min(i+context+1, len(d.Lines))
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = max(hunks[n-1][1], end)
+			continue
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	return hunks
+}
+
+// hunkAnchor returns the last line number before a hunk on one side
+func hunkAnchor(before []DiffLine, line func(DiffLine) int) int {
+	for i := len(before) - 1; i >= 0; i-- {
+		if n := line(before[i]); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// HTML renders the diff as an HTML fragment. Every paragraph is a p
+// element; removed and added paragraphs are wrapped in del and ins and
+// carry the diff-delete and diff-insert classes.
+func (d *VersionDiff) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<div class="diff">` + "\n")
+	for _, l := range d.Lines {
+		text := html.EscapeString(l.Text)
+		switch l.Op {
+		case DiffEqual:
+			fmt.Fprintf(&b, "<p>%s</p>\n", text)
+		case DiffInsert:
+			fmt.Fprintf(&b, "<p class=\"diff-insert\"><ins>%s</ins></p>\n", text)
+		case DiffDelete:
+			fmt.Fprintf(&b, "<p class=\"diff-delete\"><del>%s</del></p>\n", text)
+		}
+	}
+	b.WriteString("</div>\n")
+	return b.String()
+}
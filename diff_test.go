@@ -0,0 +1,206 @@
+package onlyoffice_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// newDocx returns a docx file with one paragraph per string
+func newDocx(t *testing.T, paragraphs ...string) []byte {
+	t.Helper()
+
+	var body strings.Builder
+	for _, p := range paragraphs {
+		body.WriteString(`<w:p><w:pPr><w:tabs><w:tab w:val="left" w:pos="720"/></w:tabs></w:pPr><w:r><w:t xml:space="preserve">` + p + `</w:t></w:r></w:p>`)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body.String() + `</w:body></w:document>`))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiffVersions(t *testing.T) {
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	contents := [][]byte{
+		newDocx(t, "Title", "One", "Two", "Three"),
+		newDocx(t, "Title", "One", "2 &amp; 3", "Three", "Four"),
+		newDocx(t, "Title", "Three", "Four"),
+	}
+	for i, content := range contents {
+		if _, err := st.Put(ctx, "a.docx", bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if i < len(contents)-1 {
+			if _, err := client.CreateHistory("a.docx", models.Callback{Key: "k"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	d, err := client.DiffVersions("a.docx", 1, 2, onlyoffice.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Added != 2 || d.Removed != 1 {
+		t.Errorf("Expected 2 added and 1 removed, got %d and %d", d.Added, d.Removed)
+	}
+	want := `--- a.docx (version 1)
++++ a.docx (version 2)
+@@ -2,3 +2,4 @@
+ One
+-Two
++2 & 3
+ Three
++Four
+`
+	if got := d.Unified(1); got != want {
+		t.Errorf("Unexpected unified diff:\n%s", got)
+	}
+	if html := d.HTML(); !strings.Contains(html, `<p class="diff-insert"><ins>2 &amp; 3</ins></p>`) || !strings.Contains(html, `<del>Two</del>`) {
+		t.Errorf("Unexpected HTML diff:\n%s", html)
+	}
+
+	// Against the current document
+	d, err = client.DiffVersions("a.docx", 2, onlyoffice.CurrentVersion, onlyoffice.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `--- a.docx (version 2)
++++ a.docx (current)
+@@ -1,4 +1,2 @@
+ Title
+-One
+-2 & 3
+ Three
+`
+	if got := d.Unified(1); got != want {
+		t.Errorf("Unexpected unified diff:\n%s", got)
+	}
+
+	if _, err := client.DiffVersions("a.docx", 1, 9, onlyoffice.DiffOptions{}); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
+
+func TestDiffVersionsConvert(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ConvertService.ashx":
+			var req struct {
+				URL        string `json:"url"`
+				OutputType string `json:"outputtype"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.OutputType != "txt" {
+				http.Error(w, "unexpected output type", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"fileUrl":    server.URL + "/out/" + strings.TrimPrefix(req.URL, "https://app/"),
+				"endConvert": true,
+			})
+		case "/out/current":
+			w.Write([]byte("\ufeffFirst\r\nSecond\r\n"))
+		case "/out/1":
+			w.Write([]byte("\ufeffFirst\r\n"))
+		}
+	}))
+	defer server.Close()
+
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{DocumentServerURL: server.URL, Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"old", "new"} {
+		if _, err := st.Put(context.Background(), "a.odt", strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if content == "old" {
+			if _, err := client.CreateHistory("a.odt", models.Callback{Key: "k"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Without a conversion URL, odt is not supported
+	if _, err := client.DiffVersions("a.odt", 1, onlyoffice.CurrentVersion, onlyoffice.DiffOptions{}); err == nil {
+		t.Fatal("Expected error for unsupported file type")
+	}
+
+	d, err := client.DiffVersions("a.odt", 1, onlyoffice.CurrentVersion, onlyoffice.DiffOptions{
+		ConvertURL: func(docID string, version int) (string, error) {
+			if version == onlyoffice.CurrentVersion {
+				return "https://app/current", nil
+			}
+			return "https://app/1", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Added != 1 || d.Removed != 0 || d.Lines[1].Text != "Second" {
+		t.Errorf("Unexpected diff %+v", d)
+	}
+}
+
+func TestDiffVersionsRewrite(t *testing.T) {
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A rewritten document falls back to a whole-region diff instead of
+	// searching the full edit space
+	const n = 20000
+	for _, prefix := range []string{"old", "new"} {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "%s paragraph %d\n", prefix, i)
+		}
+		if _, err := st.Put(context.Background(), "a.txt", strings.NewReader(b.String())); err != nil {
+			t.Fatal(err)
+		}
+		if prefix == "old" {
+			if _, err := client.CreateHistory("a.txt", models.Callback{Key: "k"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	d, err := client.DiffVersions("a.txt", 1, onlyoffice.CurrentVersion, onlyoffice.DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Added != n || d.Removed != n {
+		t.Errorf("Expected %d added and removed, got %d and %d", n, d.Added, d.Removed)
+	}
+}
@@ -0,0 +1,312 @@
+package onlyoffice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+// DocumentEventType is the kind of a DocumentEvent
+type DocumentEventType string
+
+const (
+	EventEditingStarted DocumentEventType = "editing-started" // first user opened the document
+	EventUserJoined     DocumentEventType = "user-joined"
+	EventUserLeft       DocumentEventType = "user-left"
+	EventSaved          DocumentEventType = "saved"       // status 2 handled successfully
+	EventForceSaved     DocumentEventType = "force-saved" // status 6 handled successfully
+	EventSaveError      DocumentEventType = "save-error"  // status 3 or 7
+	EventClosed         DocumentEventType = "closed"      // editing session is over
+)
+
+// DocumentEvent is a callback-derived event streamed to browsers
+type DocumentEvent struct {
+	ID     uint64            `json:"id"`
+	Type   DocumentEventType `json:"type"`
+	Key    string            `json:"key"`
+	UserID string            `json:"userId,omitempty"`
+	At     time.Time         `json:"at"`
+}
+
+// EventStream is an http.Handler streaming document events as Server-Sent
+// Events. Feed it by adding Middleware to a CallbackHandler. Clients select
+// documents with one or more "key" query parameters and resume after a
+// reconnect through the Last-Event-ID header. Event IDs start from the
+// current time in microseconds, so they keep increasing across restarts.
+type EventStream struct {
+	// Heartbeat is the interval of keep-alive comments; default 15s
+	Heartbeat time.Duration
+	// Backlog is the number of recent events kept for reconnecting clients;
+	// default 1024
+	Backlog int
+	// Authorize is called with the requested keys before a stream is opened.
+	// An error rejects the request with 403. Every request is rejected while
+	// Authorize is nil; set it to a function returning nil to serve an
+	// unauthenticated stream.
+	Authorize func(r *http.Request, keys []string) error
+	// Sessions is the tracker presence events are read from. Set it to the
+	// tracker attached to the handler with WithSessionTracker; when nil, the
+	// stream keeps its own and feeds it from Middleware. Fields are read when
+	// the stream is first used, so set them in the struct literal or pass
+	// them to NewEventStream.
+	Sessions *SessionTracker
+
+	once    sync.Once
+	mu      sync.Mutex
+	seq     uint64
+	backlog []DocumentEvent
+	subs    map[*eventSubscriber]struct{}
+	observe bool // Middleware feeds Sessions
+}
+
+// eventSubscriber is a connected client
+type eventSubscriber struct {
+	keys map[string]bool // nil means all documents
+	ch   chan DocumentEvent
+}
+
+func (sub *eventSubscriber) wants(ev DocumentEvent) bool {
+	return sub.keys == nil || sub.keys[ev.Key]
+}
+
+// NewEventStream returns an EventStream with default settings reading
+// presence from sessions, which may be nil, and authorizing streams with
+// authorize
+func NewEventStream(sessions *SessionTracker, authorize func(r *http.Request, keys []string) error) *EventStream {
+	s := &EventStream{Sessions: sessions, Authorize: authorize}
+	s.init()
+	return s
+}
+
+func (s *EventStream) init() {
+	s.once.Do(func() {
+		if s.Heartbeat <= 0 {
+			s.Heartbeat = 15 * time.Second
+		}
+		if s.Backlog <= 0 {
+			s.Backlog = 1024
+		}
+		s.seq = uint64(time.Now().UnixMicro())
+		s.subs = make(map[*eventSubscriber]struct{})
+		if s.Sessions == nil {
+			s.Sessions = NewSessionTracker()
+			s.observe = true
+		}
+		s.Sessions.listen(s.publishPresence)
+	})
+}
+
+// publishPresence turns a presence event into document events
+func (s *EventStream) publishPresence(p PresenceEvent, editors int) {
+	typ := EventUserJoined
+	if p.Type == PresenceLeave {
+		typ = EventUserLeft
+	} else if editors == 1 {
+		s.Publish(DocumentEvent{Type: EventEditingStarted, Key: p.Key, At: p.At})
+	}
+	s.Publish(DocumentEvent{Type: typ, Key: p.Key, UserID: p.UserID, At: p.At})
+}
+
+// Middleware returns the callback middleware publishing events. Presence
+// events are published as Sessions observes callbacks; save events once the
+// handlers have succeeded.
+func (s *EventStream) Middleware() CallbackMiddleware {
+	s.init()
+
+	return func(next CallbackEventFunc) CallbackEventFunc {
+		return func(ctx context.Context, ev *CallbackEvent) error {
+			cb := ev.Callback
+			at := ev.ReceivedAt
+			if at.IsZero() {
+				at = time.Now()
+			}
+
+			if s.observe {
+				s.Sessions.Observe(cb, at)
+			}
+
+			if err := next(ctx, ev); err != nil {
+				return err
+			}
+
+			switch cb.Status {
+			case models.StatusMustSave:
+				s.Publish(DocumentEvent{Type: EventSaved, Key: cb.Key, At: at})
+				s.Publish(DocumentEvent{Type: EventClosed, Key: cb.Key, At: at})
+			case models.StatusForceSave:
+				s.Publish(DocumentEvent{Type: EventForceSaved, Key: cb.Key, At: at})
+			case models.StatusSaveError, models.StatusCorrupted:
+				s.Publish(DocumentEvent{Type: EventSaveError, Key: cb.Key, At: at})
+				if cb.Status == models.StatusSaveError {
+					s.Publish(DocumentEvent{Type: EventClosed, Key: cb.Key, At: at})
+				}
+			case models.StatusClosed:
+				s.Publish(DocumentEvent{Type: EventClosed, Key: cb.Key, At: at})
+			}
+			return nil
+		}
+	}
+}
+
+// Publish assigns ev the next event ID and sends it to the subscribers.
+// Subscribers that are not keeping up are disconnected; they catch up from
+// the backlog when they reconnect.
+func (s *EventStream) Publish(ev DocumentEvent) {
+	s.init()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	ev.ID = s.seq
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	s.backlog = append(s.backlog, ev)
+	if len(s.backlog) > s.Backlog {
+		s.backlog = s.backlog[len(s.backlog)-s.Backlog:]
+	}
+
+	for sub := range s.subs {
+		if !sub.wants(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			delete(s.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the backlog after lastID. A
+// lastID the stream has not reached yet, as sent by a client of a stream
+// with a skewed clock, is ignored and returned as 0.
+func (s *EventStream) subscribe(keys map[string]bool, lastID uint64, resume bool) (*eventSubscriber, []DocumentEvent, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := &eventSubscriber{keys: keys, ch: make(chan DocumentEvent, 64)}
+	s.subs[sub] = struct{}{}
+
+	if lastID > s.seq {
+		lastID, resume = 0, false
+	}
+	var missed []DocumentEvent
+	if resume {
+		for _, ev := range s.backlog {
+			if ev.ID > lastID && sub.wants(ev) {
+				missed = append(missed, ev)
+			}
+		}
+	}
+	return sub, missed, lastID
+}
+
+func (s *EventStream) unsubscribe(sub *eventSubscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[sub]; ok {
+		delete(s.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.init()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	requested := r.URL.Query()["key"]
+	if s.Authorize == nil || s.Authorize(r, requested) != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	var keys map[string]bool
+	if len(requested) > 0 {
+		keys = make(map[string]bool, len(requested))
+		for _, k := range requested {
+			keys[k] = true
+		}
+	}
+
+	lastID, resume := uint64(0), false
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+			lastID, resume = id, true
+		}
+	}
+
+	sub, missed, lastID := s.subscribe(keys, lastID, resume)
+	defer s.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+
+	for _, ev := range missed {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			if ev.ID <= lastID {
+				continue
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes ev in the text/event-stream format
+func writeEvent(w http.ResponseWriter, ev DocumentEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
@@ -0,0 +1,166 @@
+package onlyoffice_test
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+)
+
+// readEvents reads n events from an SSE stream and returns "type:key:user"
+// entries together with the last event id
+func readEvents(t *testing.T, r *bufio.Reader, n int) ([]string, string) {
+	t.Helper()
+
+	var events []string
+	var id, typ, key, user string
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := strings.TrimPrefix(line, "data: ")
+			key = between(data, `"key":"`, `"`)
+			user = between(data, `"userId":"`, `"`)
+		case line == "" && typ != "":
+			events = append(events, typ+":"+key+":"+user)
+			typ, key, user = "", "", ""
+		}
+	}
+	return events, id
+}
+
+func between(s, start, end string) string {
+	i := strings.Index(s, start)
+	if i < 0 {
+		return ""
+	}
+	s = s[i+len(start):]
+	return s[:strings.Index(s, end)]
+}
+
+func openStream(t *testing.T, url, lastID string) (*bufio.Reader, func()) {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+	return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+}
+
+func TestEventStream(t *testing.T) {
+	client := newTestClient(t)
+	tracker := onlyoffice.NewSessionTracker()
+	stream := onlyoffice.NewEventStream(tracker, allowStream)
+	stream.Heartbeat = 10 * time.Millisecond
+	srv := httptest.NewServer(stream)
+	defer srv.Close()
+
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{},
+		onlyoffice.WithSessionTracker(tracker),
+		onlyoffice.WithMiddleware(stream.Middleware()))
+
+	r, closeStream := openStream(t, srv.URL+"?key=doc1", "")
+
+	postCallback(h, `{"status": 1, "key": "doc2", "users": ["carol"], "actions": [{"type": 1, "userid": "carol"}]}`)
+	postCallback(h, `{"status": 1, "key": "doc1", "users": ["alice"], "actions": [{"type": 1, "userid": "alice"}]}`)
+	postCallback(h, `{"status": 6, "key": "doc1", "users": ["alice"], "forcesavetype": 1}`)
+
+	events, lastID := readEvents(t, r, 3)
+	want := "editing-started:doc1: user-joined:doc1:alice force-saved:doc1:"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("Events = %q, want %q", got, want)
+	}
+
+	// Events published while disconnected are delivered after reconnecting
+	closeStream()
+	postCallback(h, `{"status": 2, "key": "doc1", "url": "https://example.com/out.docx"}`)
+
+	r, closeStream = openStream(t, srv.URL+"?key=doc1", lastID)
+	defer closeStream()
+	events, _ = readEvents(t, r, 3)
+	want = "user-left:doc1:alice saved:doc1: closed:doc1:"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("Events after reconnect = %q, want %q", got, want)
+	}
+
+	// Heartbeats keep the connection alive
+	line, _ := r.ReadString('\n')
+	if line != ": heartbeat\n" {
+		t.Errorf("Expected heartbeat, got %q", line)
+	}
+}
+
+func TestEventStreamAuthorize(t *testing.T) {
+	// Without an authorizer every stream is refused
+	rec := httptest.NewRecorder()
+	onlyoffice.NewEventStream(nil, nil).ServeHTTP(rec, httptest.NewRequest("GET", "/events?key=doc1", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without Authorize, got %d", rec.Code)
+	}
+
+	stream := onlyoffice.NewEventStream(nil, func(r *http.Request, keys []string) error {
+		if len(keys) == 0 {
+			return http.ErrNoCookie
+		}
+		return nil
+	})
+
+	rec = httptest.NewRecorder()
+	stream.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+}
+
+func TestEventStreamRestart(t *testing.T) {
+	before := &onlyoffice.EventStream{Authorize: allowStream}
+	srv := httptest.NewServer(before)
+	r, closeStream := openStream(t, srv.URL, "")
+	before.Publish(onlyoffice.DocumentEvent{Type: onlyoffice.EventSaved, Key: "doc1"})
+	_, lastID := readEvents(t, r, 1)
+	closeStream()
+	srv.Close()
+
+	// A client resuming from the previous process still gets new events
+	after := onlyoffice.NewEventStream(nil, allowStream)
+	srv = httptest.NewServer(after)
+	defer srv.Close()
+
+	// So does a client whose ID is ahead of the stream
+	ahead := strconv.FormatUint(math.MaxUint64, 10)
+	for _, lastID := range []string{lastID, ahead} {
+		r, closeStream := openStream(t, srv.URL+"?key=doc1", lastID)
+		after.Publish(onlyoffice.DocumentEvent{Type: onlyoffice.EventClosed, Key: "doc1"})
+		events, _ := readEvents(t, r, 1)
+		closeStream()
+		if len(events) != 1 || events[0] != "closed:doc1:" {
+			t.Errorf("Events after Last-Event-ID %s = %v", lastID, events)
+		}
+	}
+}
+
+func allowStream(r *http.Request, keys []string) error {
+	return nil
+}
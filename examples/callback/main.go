@@ -1,9 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -13,10 +13,12 @@ import (
 
 	"github.com/royalrick/go-onlyoffice"
 	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
 )
 
 var (
 	savedFiles = make(map[string]string) // key -> saved path
+	currentKey string                    // document key after the latest save
 	mu         sync.Mutex
 )
 
@@ -36,9 +38,8 @@ func main() {
 	}
 
 	// 创建存储目录
-	storageDir := "./storage"
-	savedDir := filepath.Join(storageDir, "saved")
-	if err := os.MkdirAll(savedDir, 0755); err != nil {
+	storageDir := "./data"
+	if err := os.MkdirAll(storageDir, 0755); err != nil {
 		log.Fatalf("创建存储目录失败: %v", err)
 	}
 
@@ -52,8 +53,11 @@ func main() {
 		fmt.Printf("✓ 已创建示例文档: %s\n", sampleFile)
 	}
 
+	docs := storage.NewFS(storageDir)
+
 	// 设置路由
-	http.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(storageDir))))
+	// 文件服务 - 仅接受带签名的 URL 或 Document Server 的 JWT
+	http.Handle("/files/", http.StripPrefix("/files/", client.FileHandler(docs)))
 
 	// 编辑器页面
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -71,9 +75,12 @@ func main() {
 			log.Printf("📝 文档正在编辑 - Key: %s, Users: %v", cb.Key, cb.Users)
 			return nil
 		},
-		OnSave: func(cb *models.Callback) error {
-			log.Printf("💾 文档准备保存 - Key: %s, URL: %s", cb.Key, cb.Url)
-			return saveDocument(cb, savedDir)
+		// 自动保存: 下载文档、保留上一版本并记录历史
+		AutoSave: &onlyoffice.AutoSave{Storage: docs},
+		OnSaveEvent: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			log.Printf("💾 文档已保存 - Key: %s, 新 Key: %s", ev.Callback.Key, ev.Saved.Key)
+			recordSaved(ev.Saved)
+			return nil
 		},
 		OnSaveError: func(cb *models.Callback) error {
 			log.Printf("❌ 文档保存出错 - Key: %s", cb.Key)
@@ -83,9 +90,10 @@ func main() {
 			log.Printf("🚪 文档已关闭(无修改) - Key: %s", cb.Key)
 			return nil
 		},
-		OnForceSave: func(cb *models.Callback) error {
-			log.Printf("⚡ 文档强制保存 - Key: %s, URL: %s", cb.Key, cb.Url)
-			return saveDocument(cb, savedDir)
+		OnForceSaveEvent: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			log.Printf("⚡ 文档强制保存 - Key: %s, 新 Key: %s", ev.Callback.Key, ev.Saved.Key)
+			recordSaved(ev.Saved)
+			return nil
 		},
 		OnCorrupt: func(cb *models.Callback) error {
 			log.Printf("⚠️  文档已损坏 - Key: %s", cb.Key)
@@ -112,47 +120,12 @@ func main() {
 	log.Fatal(http.ListenAndServe(addr, nil))
 }
 
-func saveDocument(cb *models.Callback, savedDir string) error {
-	if cb.Url == "" {
-		return fmt.Errorf("empty download URL")
-	}
-
-	// 下载文档
-	log.Printf("  开始下载文档: %s", cb.Url)
-	resp, err := http.Get(cb.Url)
-	if err != nil {
-		return fmt.Errorf("下载失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("下载失败，状态码: %d", resp.StatusCode)
-	}
-
-	// 保存文档
-	timestamp := time.Now().Format("20060102-150405")
-	filename := fmt.Sprintf("document-%s.docx", timestamp)
-	savePath := filepath.Join(savedDir, filename)
-
-	file, err := os.Create(savePath)
-	if err != nil {
-		return fmt.Errorf("创建文件失败: %w", err)
-	}
-	defer file.Close()
-
-	written, err := io.Copy(file, resp.Body)
-	if err != nil {
-		return fmt.Errorf("保存文件失败: %w", err)
-	}
-
-	log.Printf("  ✓ 文档已保存: %s (%d 字节)", savePath, written)
-
-	// 记录保存的文件
+func recordSaved(res *onlyoffice.SaveResult) {
 	mu.Lock()
-	savedFiles[cb.Key] = savePath
-	mu.Unlock()
+	defer mu.Unlock()
 
-	return nil
+	savedFiles[res.Key] = filepath.Join("storage", res.Name)
+	currentKey = res.Key
 }
 
 func serveEditorPage(w http.ResponseWriter, r *http.Request, client *onlyoffice.Client) {
@@ -268,11 +241,20 @@ func serveEditorPage(w http.ResponseWriter, r *http.Request, client *onlyoffice.
 
 func serveEditorConfig(w http.ResponseWriter, r *http.Request, client *onlyoffice.Client) {
 	host := r.Host
-	fileURL := fmt.Sprintf("http://%s/files/document.docx", host)
-	callbackURL := fmt.Sprintf("http://%s/callback", host)
+	fileURL, err := client.SignFileURL(fmt.Sprintf("http://%s/files", host), "document.docx", 10*time.Minute)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("生成文件地址失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	callbackURL := fmt.Sprintf("http://%s/callback?filename=document.docx", host)
+
+	mu.Lock()
+	key := currentKey
+	mu.Unlock()
 
 	params := models.EditorParams{
 		Filename:    "document.docx",
+		Key:         key,
 		Mode:        "edit",
 		Language:    "zh-CN",
 		UserId:      "user123",
@@ -45,7 +45,7 @@ func main() {
 	fmt.Printf("✓ 使用主机 IP: %s\n", hostIP)
 
 	// 启动简单的文件服务器来提供源文件
-	storageDir := "./storage"
+	storageDir := "./data"
 	if err := os.MkdirAll(storageDir, 0755); err != nil {
 		log.Fatalf("创建存储目录失败: %v", err)
 	}
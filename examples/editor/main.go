@@ -28,7 +28,7 @@ func main() {
 	}
 
 	// 创建存储目录和示例文档
-	storageDir := "./storage"
+	storageDir := "./data"
 	if err := os.MkdirAll(storageDir, 0755); err != nil {
 		log.Fatalf("创建存储目录失败: %v", err)
 	}
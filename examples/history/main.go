@@ -4,22 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/royalrick/go-onlyoffice"
 	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
 )
 
 func main() {
 	fmt.Println("=== OnlyOffice 文档版本历史管理示例 ===")
 
-	// 配置客户端
+	// 创建存储目录
+	storageDir := "./data"
+	if err := os.MkdirAll(storageDir, 0755); err != nil {
+		log.Fatalf("创建存储目录失败: %v", err)
+	}
+
+	// 配置客户端，历史版本保存在 Storage 中
 	config := &onlyoffice.Config{
 		DocumentServerURL: getEnv("ONLYOFFICE_URL", "http://localhost"),
 		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key"),
 		JWTEnabled:        getEnv("JWT_ENABLED", "false") == "true",
+		Storage:           storage.NewFS(storageDir),
 	}
 
 	client, err := onlyoffice.NewClient(config)
@@ -27,16 +36,13 @@ func main() {
 		log.Fatalf("初始化客户端失败: %v", err)
 	}
 
-	// 创建存储目录
-	storageDir := "./storage"
-	if err := os.MkdirAll(storageDir, 0755); err != nil {
-		log.Fatalf("创建存储目录失败: %v", err)
-	}
+	// 文档 ID，通常是文档在存储中的名称
+	docID := "test-document.docx"
 
 	fmt.Println("\n--- 示例 1: 创建文档历史版本 ---")
 
 	// 模拟文档的多次编辑和保存
-	documentKey, err := client.GenerateFileHash("test-document.docx")
+	documentKey, err := client.GenerateFileHash(docID)
 	if err != nil {
 		log.Fatalf("生成文档键失败: %v", err)
 	}
@@ -46,10 +52,10 @@ func main() {
 	// 模拟第一次保存
 	fmt.Println("1. 模拟第一次保存...")
 	callback1 := createMockCallback(documentKey, 1, "user1", "张三")
-	if err := client.CreateHistory(callback1, storageDir); err != nil {
+	if v, err := client.CreateHistory(docID, callback1); err != nil {
 		log.Printf("创建历史失败: %v", err)
 	} else {
-		fmt.Println("   ✓ 版本 1 已保存")
+		fmt.Printf("   ✓ 版本 %d 已保存\n", v.Version)
 	}
 
 	time.Sleep(time.Second)
@@ -57,10 +63,10 @@ func main() {
 	// 模拟第二次保存
 	fmt.Println("2. 模拟第二次保存...")
 	callback2 := createMockCallback(documentKey, 2, "user2", "李四")
-	if err := client.CreateHistory(callback2, storageDir); err != nil {
+	if v, err := client.CreateHistory(docID, callback2); err != nil {
 		log.Printf("创建历史失败: %v", err)
 	} else {
-		fmt.Println("   ✓ 版本 2 已保存")
+		fmt.Printf("   ✓ 版本 %d 已保存\n", v.Version)
 	}
 
 	time.Sleep(time.Second)
@@ -68,24 +74,25 @@ func main() {
 	// 模拟第三次保存
 	fmt.Println("3. 模拟第三次保存...")
 	callback3 := createMockCallback(documentKey, 3, "user1", "张三")
-	if err := client.CreateHistory(callback3, storageDir); err != nil {
+	if v, err := client.CreateHistory(docID, callback3); err != nil {
 		log.Printf("创建历史失败: %v", err)
 	} else {
-		fmt.Println("   ✓ 版本 3 已保存")
+		fmt.Printf("   ✓ 版本 %d 已保存\n", v.Version)
 	}
 
 	fmt.Println("\n--- 示例 2: 查询历史版本 ---")
 
 	// 查询版本历史
-	versions, err := client.GetHistory("test-document.docx", storageDir)
+	versions, err := client.GetHistory(docID)
 	if err != nil {
 		log.Printf("查询历史失败: %v", err)
 	} else {
 		fmt.Printf("\n找到 %d 个历史版本:\n\n", len(versions))
-		for i, version := range versions {
-			fmt.Printf("版本 %d:\n", i+1)
+		for _, version := range versions {
+			fmt.Printf("版本 %d:\n", version.Version)
 			fmt.Printf("  文件键: %s\n", version.Key)
 			fmt.Printf("  创建时间: %s\n", version.Created.Format("2006-01-02 15:04:05"))
+			fmt.Printf("  服务器版本: %s\n", version.ServerVersion)
 			if version.User != nil {
 				fmt.Printf("  用户: %s (ID: %s)\n", version.User.Name, version.User.Id)
 			}
@@ -101,12 +108,15 @@ func main() {
 
 	fmt.Println("\n--- 示例 3: 统计版本数量 ---")
 
-	count := client.CountVersion(storageDir)
+	count, err := client.CountVersion(docID)
+	if err != nil {
+		log.Fatalf("统计版本失败: %v", err)
+	}
 	fmt.Printf("共有 %d 个版本记录\n", count)
 
 	fmt.Println("\n--- 示例 4: 查看存储结构 ---")
 
-	historyDir := filepath.Join(storageDir, ".history")
+	historyDir := filepath.Join(storageDir, ".history", url.PathEscape(docID))
 	if _, err := os.Stat(historyDir); err == nil {
 		fmt.Printf("\n历史记录存储在: %s\n", historyDir)
 		fmt.Println("目录结构:")
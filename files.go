@@ -0,0 +1,190 @@
+package onlyoffice
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// ErrNoSigningSecret is returned when file URLs are signed or verified
+// without FileSigningSecret or JWTSecret configured
+var ErrNoSigningSecret = errors.New("onlyoffice: no file signing secret configured")
+
+// fileHandler implements http.Handler serving documents to the Document Server
+type fileHandler struct {
+	client  *Client
+	storage storage.Storage
+}
+
+// FileHandler returns an http.Handler that serves documents from st. A
+// request is only served when it carries a valid signature created by
+// SignFileURL, or a Document Server JWT in the Authorization header. The
+// handler expects to be mounted with http.StripPrefix so that the remaining
+// path is the file name.
+func (c *Client) FileHandler(st storage.Storage) http.Handler {
+	return &fileHandler{client: c, storage: st}
+}
+
+// SignFileURL returns the URL of name below baseURL, signed so that
+// FileHandler accepts it until ttl has elapsed.
+func (c *Client) SignFileURL(baseURL, name string, ttl time.Duration) (string, error) {
+	name, err := storage.CleanName(name)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(strings.TrimRight(baseURL, "/") + "/" + (&url.URL{Path: name}).EscapedPath())
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	sig, err := c.fileSignature(name, expires)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("expires", expires)
+	q.Set("signature", sig)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, err := storage.CleanName(r.URL.Path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := h.authorize(r, name); err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	body, info, err := h.storage.Get(r.Context(), name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("ETag", info.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	// Range requests need random access
+	if rs, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(name), info.ModTime, rs)
+		return
+	}
+
+	if info.ETag != "" && r.Header.Get("If-None-Match") == info.ETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, body)
+}
+
+// authorize checks the URL signature first and falls back to the JWT the
+// Document Server sends when it downloads a file
+func (h *fileHandler) authorize(r *http.Request, name string) error {
+	q := r.URL.Query()
+	if sig := q.Get("signature"); sig != "" {
+		expires := q.Get("expires")
+		exp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return errors.New("invalid expiry")
+		}
+		if time.Now().Unix() > exp {
+			return errors.New("signature expired")
+		}
+		want, err := h.client.fileSignature(name, expires)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(sig), []byte(want)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !h.client.config.JWTEnabled || !strings.HasPrefix(auth, "Bearer ") {
+		return errors.New("missing credentials")
+	}
+
+	claims, err := h.client.ParseToken(strings.TrimPrefix(auth, "Bearer "))
+	if err != nil {
+		return err
+	}
+
+	// The download token carries the requested URL, either at the top level
+	// or wrapped in a "payload" object, whose path below the mount point must
+	// be the requested file. Tokens without it, such as editor config tokens,
+	// do not grant access to files.
+	if p, ok := claims["payload"].(map[string]any); ok {
+		claims = p
+	}
+	tokenURL, ok := claims["url"].(string)
+	if !ok {
+		return errors.New("token has no url")
+	}
+	u, err := url.Parse(tokenURL)
+	if err != nil {
+		return errors.New("token does not match file")
+	}
+	rel, ok := strings.CutPrefix(u.Path, mountPath(r))
+	if tokenName, err := storage.CleanName(rel); !ok || err != nil || tokenName != name {
+		return errors.New("token does not match file")
+	}
+
+	return nil
+}
+
+// mountPath returns the path the handler is mounted at, that is the part of
+// the request path removed by http.StripPrefix
+func mountPath(r *http.Request) string {
+	full := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		full = u.Path
+	}
+	return strings.TrimSuffix(full, r.URL.Path)
+}
+
+// fileSignature returns the hex HMAC-SHA256 of name and expires
+func (c *Client) fileSignature(name, expires string) (string, error) {
+	secret := c.config.FileSigningSecret
+	if secret == "" {
+		secret = c.config.JWTSecret
+	}
+	if secret == "" {
+		return "", ErrNoSigningSecret
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(name + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
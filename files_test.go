@@ -0,0 +1,167 @@
+package onlyoffice_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func newFileServer(t *testing.T) (*onlyoffice.Client, *httptest.Server) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.txt"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{
+		DocumentServerURL: "https://example.com",
+		JWTSecret:         "secret",
+		JWTEnabled:        true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	srv := httptest.NewServer(http.StripPrefix("/files/", client.FileHandler(storage.NewFS(dir))))
+	t.Cleanup(srv.Close)
+	return client, srv
+}
+
+func TestFileHandlerSignedURL(t *testing.T) {
+	client, srv := newFileServer(t)
+
+	signed, err := client.SignFileURL(srv.URL+"/files", "doc.txt", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to sign url: %v", err)
+	}
+
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+
+	req, _ := http.NewRequest("GET", signed, nil)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || resp.ContentLength != 3 {
+		t.Errorf("Expected 206 with 3 bytes, got %d with %d", resp.StatusCode, resp.ContentLength)
+	}
+
+	req, _ = http.NewRequest("GET", signed, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", resp.StatusCode)
+	}
+
+	for _, u := range []string{
+		srv.URL + "/files/doc.txt",
+		strings.Replace(signed, "doc.txt", "other.txt", 1),
+		strings.Replace(signed, "signature=", "signature=00", 1),
+	} {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("GET %s: expected 403, got %d", u, resp.StatusCode)
+		}
+	}
+
+	expired, _ := client.SignFileURL(srv.URL+"/files", "doc.txt", -time.Minute)
+	resp, err = http.Get(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for expired url, got %d", resp.StatusCode)
+	}
+}
+
+func TestFileHandlerJWT(t *testing.T) {
+	client, srv := newFileServer(t)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		status int
+	}{
+		{"NoURL", jwt.MapClaims{}, http.StatusForbidden},
+		{"MatchingURL", jwt.MapClaims{"payload": map[string]any{"url": srv.URL + "/files/doc.txt"}}, http.StatusOK},
+		{"OtherURL", jwt.MapClaims{"url": srv.URL + "/files/other.txt"}, http.StatusForbidden},
+		{"NestedURL", jwt.MapClaims{"url": srv.URL + "/files/dir/doc.txt"}, http.StatusForbidden},
+		{"OtherMount", jwt.MapClaims{"url": srv.URL + "/other/doc.txt"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := client.CreateToken(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := http.NewRequest("GET", srv.URL+"/files/doc.txt", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestFileHandlerNoSecret(t *testing.T) {
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{DocumentServerURL: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SignFileURL("https://app/files", "doc.txt", time.Minute); !errors.Is(err, onlyoffice.ErrNoSigningSecret) {
+		t.Errorf("Expected ErrNoSigningSecret, got %v", err)
+	}
+
+	// A signature made with an empty key is not accepted
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte("doc.txt\n9999999999"))
+	forged := "/doc.txt?expires=9999999999&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	rec := httptest.NewRecorder()
+	client.FileHandler(storage.NewMemory()).ServeHTTP(rec, httptest.NewRequest("GET", forged, nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package onlyoffice
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FormSubmitFunc handles a form submission (forcesave type 3) together with
+// the submitted field values
+type FormSubmitFunc func(ctx context.Context, ev *CallbackEvent, form *FormData) error
+
+// FormField is a single submitted form field
+type FormField struct {
+	Key   string          `json:"key"`
+	Tag   string          `json:"tag,omitempty"`
+	Type  string          `json:"type,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+// String returns the value as text. Booleans and numbers are formatted,
+// null becomes the empty string.
+func (f FormField) String() string {
+	var s string
+	if err := json.Unmarshal(f.Value, &s); err == nil {
+		return s
+	}
+	if string(f.Value) == "null" {
+		return ""
+	}
+	return string(f.Value)
+}
+
+// Bool returns the value of a check box or radio button
+func (f FormField) Bool() bool {
+	b, _ := strconv.ParseBool(f.String())
+	return b
+}
+
+// FormData is the content of the formsdataurl of a form submission
+type FormData struct {
+	Fields []FormField
+}
+
+// Get returns the field with the given key
+func (d *FormData) Get(key string) (FormField, bool) {
+	for _, f := range d.Fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return FormField{}, false
+}
+
+// Values returns the field values as text keyed by field key
+func (d *FormData) Values() map[string]string {
+	values := make(map[string]string, len(d.Fields))
+	for _, f := range d.Fields {
+		values[f.Key] = f.String()
+	}
+	return values
+}
+
+// Decode stores the field values in the struct pointed to by v. A struct
+// field is matched by its `form` tag, or by its name when untagged; a tag
+// of "-" skips the field. Supported field types are strings, booleans,
+// numbers and encoding.TextUnmarshaler implementations.
+func (d *FormData) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode form: expected pointer to struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := sf.Tag.Get("form")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = sf.Name
+		}
+
+		field, ok := d.Get(key)
+		if !ok {
+			continue
+		}
+		if err := setFormValue(rv.Field(i), field.String()); err != nil {
+			return fmt.Errorf("decode form field %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// setFormValue parses s into v
+func setFormValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	s = strings.TrimSpace(s)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			v.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			return nil
+		}
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			return nil
+		}
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// ParseFormData parses the JSON document found at a formsdataurl
+func ParseFormData(r io.Reader) (*FormData, error) {
+	var fields []FormField
+	if err := json.NewDecoder(r).Decode(&fields); err != nil {
+		return nil, err
+	}
+	return &FormData{Fields: fields}, nil
+}
+
+// DownloadFormData downloads and parses the form data of a form submission
+func (c *Client) DownloadFormData(ctx context.Context, formsDataURL string) (*FormData, error) {
+	body, err := c.openURL(ctx, formsDataURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ParseFormData(body)
+}
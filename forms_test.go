@@ -0,0 +1,120 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func TestForceSaveDispatch(t *testing.T) {
+	forms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"key": "Name", "tag": "", "value": "Alice", "type": "text"},
+			{"key": "Agree", "value": true, "type": "checkBox"},
+			{"key": "Age", "value": "42", "type": "text"},
+			{"key": "Photo", "value": null, "type": "picture"}
+		]`))
+	}))
+	defer forms.Close()
+
+	type application struct {
+		Name  string
+		Agree bool
+		Age   int    `form:"Age"`
+		Photo string `form:"Photo"`
+	}
+
+	client := newTestClient(t)
+	var got []string
+	var submitted application
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnForceSave: func(cb *models.Callback) error {
+			got = append(got, "generic")
+			return nil
+		},
+		OnForceSaveButton: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			got = append(got, "button")
+			return nil
+		},
+		OnFormSubmit: func(ctx context.Context, ev *onlyoffice.CallbackEvent, form *onlyoffice.FormData) error {
+			got = append(got, "form")
+			return form.Decode(&submitted)
+		},
+	})
+
+	for _, body := range []string{
+		`{"status": 6, "key": "k", "forcesavetype": 1}`,
+		`{"status": 6, "key": "k", "forcesavetype": 2}`,
+		fmt.Sprintf(`{"status": 6, "key": "k", "forcesavetype": 3, "formsdataurl": "%s"}`, forms.URL),
+	} {
+		if rec := postCallback(h, body); rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+	}
+
+	if fmt.Sprint(got) != "[button generic form]" {
+		t.Errorf("Unexpected dispatch order %v", got)
+	}
+	if submitted != (application{Name: "Alice", Agree: true, Age: 42}) {
+		t.Errorf("Unexpected form values %+v", submitted)
+	}
+}
+
+func TestFormDataDecodeError(t *testing.T) {
+	form := &onlyoffice.FormData{Fields: []onlyoffice.FormField{{Key: "Age", Value: []byte(`"old"`)}}}
+
+	var v struct{ Age int }
+	if err := form.Decode(&v); err == nil {
+		t.Error("Expected an error for a non-numeric value")
+	}
+	if err := form.Decode(v); err == nil {
+		t.Error("Expected an error for a non-pointer target")
+	}
+	if form.Values()["Age"] != "old" {
+		t.Errorf("Unexpected values %v", form.Values())
+	}
+}
+
+func TestFormSubmitKeepsTemplate(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/filled.docx":
+			w.Write([]byte("filled"))
+		default:
+			w.Write([]byte(`[{"key": "Name", "value": "Alice", "type": "text"}]`))
+		}
+	}))
+	defer files.Close()
+
+	st := storage.NewMemory()
+	if _, err := st.Put(context.Background(), "form.docx", strings.NewReader("template")); err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t)
+	submitted := false
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		AutoSave: &onlyoffice.AutoSave{Storage: st},
+		OnFormSubmit: func(ctx context.Context, ev *onlyoffice.CallbackEvent, form *onlyoffice.FormData) error {
+			submitted = ev.Saved == nil
+			return nil
+		},
+	})
+
+	rec := postCallbackTo(h, "/callback?filename=form.docx", fmt.Sprintf(`{
+		"status": 6, "key": "k", "forcesavetype": 3,
+		"url": "%s/filled.docx", "formsdataurl": "%s/data.json"
+	}`, files.URL, files.URL))
+	if rec.Code != http.StatusOK || !submitted {
+		t.Fatalf("Expected submit without autosave, got %d", rec.Code)
+	}
+	if got := readObject(t, st, "form.docx"); got != "template" {
+		t.Errorf("Template overwritten with %q", got)
+	}
+}
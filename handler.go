@@ -1,9 +1,17 @@
 package onlyoffice
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/royalrick/go-onlyoffice/models"
 )
@@ -11,6 +19,35 @@ import (
 // CallbackHandlerFunc is a function that handles OnlyOffice callbacks
 type CallbackHandlerFunc func(cb *models.Callback) error
 
+// CallbackEventFunc is a context-aware function that handles OnlyOffice callbacks
+type CallbackEventFunc func(ctx context.Context, ev *CallbackEvent) error
+
+// CallbackEvent is a parsed callback together with the request that carried it
+type CallbackEvent struct {
+	Callback    *models.Callback
+	Request     *http.Request
+	Body        []byte // raw request body
+	ReceivedAt  time.Time
+	Saved       *SaveResult       // set once AutoSave has stored the document
+	Quarantined *QuarantineRecord // set once Quarantine has captured the callback
+}
+
+// RemoteAddr returns the network address of the Document Server
+func (ev *CallbackEvent) RemoteAddr() string {
+	if ev.Request == nil {
+		return ""
+	}
+	return ev.Request.RemoteAddr
+}
+
+// Header returns the header of the callback request
+func (ev *CallbackEvent) Header() http.Header {
+	if ev.Request == nil {
+		return http.Header{}
+	}
+	return ev.Request.Header
+}
+
 // CallbackHandlers defines handlers for different callback statuses
 type CallbackHandlers struct {
 	OnEditing   CallbackHandlerFunc // status 1: document is being edited
@@ -19,70 +56,378 @@ type CallbackHandlers struct {
 	OnClose     CallbackHandlerFunc // status 4: document is closed with no changes
 	OnForceSave CallbackHandlerFunc // status 6: document is being forcibly saved
 	OnCorrupt   CallbackHandlerFunc // status 7: document is corrupted
+
+	// Context-aware variants; when set they are used instead of the plain form
+	OnEditingEvent   CallbackEventFunc
+	OnSaveEvent      CallbackEventFunc
+	OnSaveErrorEvent CallbackEventFunc
+	OnCloseEvent     CallbackEventFunc
+	OnForceSaveEvent CallbackEventFunc
+	OnCorruptEvent   CallbackEventFunc
+
+	// Forcesave handlers by initiator; when the one matching forcesavetype is
+	// set it is used instead of OnForceSave
+	OnForceSaveCommand CallbackEventFunc // forcesavetype 0: CommandService request
+	OnForceSaveButton  CallbackEventFunc // forcesavetype 1: Save button
+	OnForceSaveTimer   CallbackEventFunc // forcesavetype 2: forcesave timer
+	OnFormSubmit       FormSubmitFunc    // forcesavetype 3: form submission, with the downloaded formsdataurl
+
+	// AutoSave, when set, stores the document of status 2 and 6 callbacks
+	// before OnSave or OnForceSave run
+	AutoSave *AutoSave
+
+	// Quarantine, when set, keeps the artifacts of status 3 and 7 callbacks
+	// before OnSaveError or OnCorrupt run
+	Quarantine *Quarantine
+}
+
+// handlerFor returns the handler registered for status, or nil
+func (hs *CallbackHandlers) handlerFor(status models.CallbackStatus) CallbackEventFunc {
+	var plain CallbackHandlerFunc
+	var event CallbackEventFunc
+	switch status {
+	case models.StatusEditing:
+		plain, event = hs.OnEditing, hs.OnEditingEvent
+	case models.StatusMustSave:
+		plain, event = hs.OnSave, hs.OnSaveEvent
+	case models.StatusSaveError:
+		plain, event = hs.OnSaveError, hs.OnSaveErrorEvent
+	case models.StatusClosed:
+		plain, event = hs.OnClose, hs.OnCloseEvent
+	case models.StatusForceSave:
+		plain, event = hs.OnForceSave, hs.OnForceSaveEvent
+	case models.StatusCorrupted:
+		plain, event = hs.OnCorrupt, hs.OnCorruptEvent
+	}
+
+	if event != nil {
+		return event
+	}
+	if plain != nil {
+		return func(ctx context.Context, ev *CallbackEvent) error {
+			return plain(ev.Callback)
+		}
+	}
+	return nil
 }
 
 // callbackHandler implements http.Handler for OnlyOffice callbacks
 type callbackHandler struct {
-	client   *Client
-	handlers CallbackHandlers
+	client     *Client
+	handlers   CallbackHandlers
+	dedup      DedupStore
+	inflight   keyedMutex
+	queue      *CallbackQueue
+	sessions   *SessionTracker
+	middleware []CallbackMiddleware
+	handle     CallbackEventFunc // dispatch wrapped in middleware
+
+	maxBodySize    int64
+	contentTypes   []string
+	allowedSources []netip.Prefix
+	reportError    ErrorReporter
+}
+
+// DefaultMaxCallbackBodySize is the default limit of a callback request body
+const DefaultMaxCallbackBodySize = 10 << 20
+
+// ErrorReporter receives the error behind every error response of the
+// callback handler, before the response is written
+type ErrorReporter func(r *http.Request, status int, err error)
+
+// CallbackOption configures the handler returned by CallbackHandler
+type CallbackOption func(*callbackHandler)
+
+// WithDedupStore makes the handler acknowledge retried deliveries of a
+// completed save, error or corruption callback without running handlers again
+func WithDedupStore(store DedupStore) CallbackOption {
+	return func(h *callbackHandler) {
+		h.dedup = store
+	}
+}
+
+// WithMaxBodySize limits the size of callback request bodies. Larger
+// requests are rejected with 413. Defaults to DefaultMaxCallbackBodySize.
+func WithMaxBodySize(n int64) CallbackOption {
+	return func(h *callbackHandler) {
+		h.maxBodySize = n
+	}
+}
+
+// WithContentTypes sets the accepted media types of callback requests;
+// others are rejected with 415. Defaults to application/json. Calling it
+// without arguments disables the check.
+func WithContentTypes(types ...string) CallbackOption {
+	return func(h *callbackHandler) {
+		h.contentTypes = types
+	}
+}
+
+// WithAllowedSources only accepts callbacks whose remote address is in one of
+// the prefixes; others are rejected with 403. Behind a reverse proxy, the
+// remote address must be restored before the handler runs.
+func WithAllowedSources(prefixes ...netip.Prefix) CallbackOption {
+	return func(h *callbackHandler) {
+		h.allowedSources = append(h.allowedSources, prefixes...)
+	}
+}
+
+// WithErrorReporter sets the function receiving the errors behind error
+// responses, e.g. for logging. Errors that do not fail the callback, such as
+// failing to record a completed callback in the dedup store, are reported
+// with status 500 as well.
+func WithErrorReporter(report ErrorReporter) CallbackOption {
+	return func(h *callbackHandler) {
+		h.reportError = report
+	}
+}
+
+// WithQueue makes the handler persist callbacks to q and reply immediately.
+// The callbacks are dispatched by the workers started with q.Run. Errors
+// persisting their outcome go to the handler's ErrorReporter.
+func WithQueue(q *CallbackQueue) CallbackOption {
+	return func(h *callbackHandler) {
+		h.queue = q
+		q.mu.Lock()
+		q.process = h.process
+		q.report = func(r *http.Request, status int, err error) {
+			if h.reportError != nil {
+				h.reportError(r, status, err)
+			}
+		}
+		q.mu.Unlock()
+	}
 }
 
 // CallbackHandler returns an http.Handler that processes OnlyOffice callbacks
-func (c *Client) CallbackHandler(handlers CallbackHandlers) http.Handler {
-	return &callbackHandler{client: c, handlers: handlers}
+func (c *Client) CallbackHandler(handlers CallbackHandlers, opts ...CallbackOption) http.Handler {
+	h := &callbackHandler{
+		client:       c,
+		handlers:     handlers,
+		maxBodySize:  DefaultMaxCallbackBodySize,
+		contentTypes: []string{"application/json"},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.handle = chain(h.dispatch, h.middleware)
+	return h
 }
 
 // ServeHTTP implements the http.Handler interface
 func (h *callbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 1. Only accept POST requests
+	// 1. Only accept POST requests of an accepted type from allowed sources
 	if r.Method != http.MethodPost {
-		h.respondError(w, http.StatusMethodNotAllowed)
+		h.respondError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	if err := h.checkSource(r); err != nil {
+		h.respondError(w, r, http.StatusForbidden, err)
+		return
+	}
+
+	if err := h.checkContentType(r); err != nil {
+		h.respondError(w, r, http.StatusUnsupportedMediaType, err)
 		return
 	}
 
 	// 2. Read request body
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
 	if err != nil {
-		h.respondError(w, http.StatusBadRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondError(w, r, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		h.respondError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	// 3. Parse callback (includes JWT validation)
 	callback, err := h.client.ParseCallback(body, r.Header.Get("Authorization"))
 	if err != nil {
-		h.respondError(w, http.StatusUnauthorized)
+		h.respondError(w, r, http.StatusUnauthorized, err)
 		return
 	}
 
-	// 4. Dispatch based on status
-	var handler CallbackHandlerFunc
-	switch callback.Status {
-	case 1:
-		handler = h.handlers.OnEditing
-	case 2:
-		handler = h.handlers.OnSave
-	case 3:
-		handler = h.handlers.OnSaveError
-	case 4:
-		handler = h.handlers.OnClose
-	case 6:
-		handler = h.handlers.OnForceSave
-	case 7:
-		handler = h.handlers.OnCorrupt
-	}
-
-	// 5. Execute handler function
-	if handler != nil {
-		if err := handler(callback); err != nil {
-			h.respondError(w, http.StatusInternalServerError)
+	ev := &CallbackEvent{
+		Callback:   callback,
+		Request:    r,
+		Body:       body,
+		ReceivedAt: time.Now(),
+	}
+
+	if h.sessions != nil {
+		h.sessions.Observe(callback, ev.ReceivedAt)
+	}
+
+	// 4. Acknowledge deliveries that have already been processed
+	if h.dedup != nil && isDedupStatus(callback.Status) {
+		seen, err := h.dedup.Seen(r.Context(), IdempotencyKey(callback))
+		if err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if seen {
+			h.respondOK(w)
 			return
 		}
 	}
 
-	// 6. Return success response
+	// 5. In queue mode, persist the callback and reply immediately
+	if h.queue != nil {
+		if err := h.queue.Enqueue(ev); err != nil {
+			h.respondError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		h.respondOK(w)
+		return
+	}
+
+	// 6. Dispatch based on status
+	if err := h.process(r.Context(), ev); err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// 7. Return success response
 	h.respondOK(w)
 }
 
+// process dispatches ev once. With a dedup store, concurrent and repeated
+// deliveries of the same callback are skipped after the first success.
+func (h *callbackHandler) process(ctx context.Context, ev *CallbackEvent) error {
+	if h.dedup == nil || !isDedupStatus(ev.Callback.Status) {
+		return h.handle(ctx, ev)
+	}
+
+	key := IdempotencyKey(ev.Callback)
+	unlock := h.inflight.lock(key)
+	defer unlock()
+
+	seen, err := h.dedup.Seen(ctx, key)
+	if err != nil || seen {
+		return err
+	}
+
+	if err := h.handle(ctx, ev); err != nil {
+		return err
+	}
+
+	// The work is done, so failing to record it must not trigger a retry
+	if err := h.dedup.Done(ctx, key); err != nil && h.reportError != nil {
+		h.reportError(ev.Request, http.StatusInternalServerError, fmt.Errorf("dedup: record %s: %w", ev.Callback.Key, err))
+	}
+	return nil
+}
+
+// dispatch runs the handler registered for the callback status
+func (h *callbackHandler) dispatch(ctx context.Context, ev *CallbackEvent) error {
+	// A form submission carries the filled-in form, which must not replace
+	// the stored form template
+	isSubmit := ev.Callback.Status == models.StatusForceSave && ev.Callback.ForceSaveType == models.ForceSaveForm
+	if h.handlers.AutoSave != nil && isSaveStatus(ev.Callback.Status) && !isSubmit {
+		saved, err := h.handlers.AutoSave.save(ctx, h.client, ev)
+		if err != nil {
+			return err
+		}
+		ev.Saved = saved
+	}
+
+	if h.handlers.Quarantine != nil && isQuarantineStatus(ev.Callback.Status) {
+		rec, err := h.handlers.Quarantine.capture(ctx, h.client, ev)
+		if err != nil {
+			return err
+		}
+		ev.Quarantined = rec
+	}
+
+	var handler CallbackEventFunc
+	if ev.Callback.Status == models.StatusForceSave {
+		handler = h.forceSaveHandlerFor(ev.Callback.ForceSaveType)
+	}
+	if handler == nil {
+		handler = h.handlers.handlerFor(ev.Callback.Status)
+	}
+	if handler == nil {
+		return nil
+	}
+	return handler(ctx, ev)
+}
+
+// checkSource rejects requests from outside the allowed source networks
+func (h *callbackHandler) checkSource(r *http.Request) error {
+	if len(h.allowedSources) == 0 {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("invalid remote address %q", r.RemoteAddr)
+	}
+
+	addr = addr.Unmap()
+	for _, p := range h.allowedSources {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source %s not allowed", addr)
+}
+
+// checkContentType rejects bodies of a media type that is not accepted
+func (h *callbackHandler) checkContentType(r *http.Request) error {
+	if len(h.contentTypes) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid content type %q", r.Header.Get("Content-Type"))
+	}
+	for _, t := range h.contentTypes {
+		if strings.EqualFold(mediaType, t) {
+			return nil
+		}
+	}
+	return fmt.Errorf("content type %q not accepted", mediaType)
+}
+
+// forceSaveHandlerFor returns the handler registered for a forcesave
+// initiator, or nil
+func (h *callbackHandler) forceSaveHandlerFor(t models.ForceSaveType) CallbackEventFunc {
+	switch t {
+	case models.ForceSaveCommand:
+		return h.handlers.OnForceSaveCommand
+	case models.ForceSaveButton:
+		return h.handlers.OnForceSaveButton
+	case models.ForceSaveTimer:
+		return h.handlers.OnForceSaveTimer
+	case models.ForceSaveForm:
+		if h.handlers.OnFormSubmit != nil {
+			return h.submitForm
+		}
+	}
+	return nil
+}
+
+// submitForm downloads the submitted form data and passes it to OnFormSubmit
+func (h *callbackHandler) submitForm(ctx context.Context, ev *CallbackEvent) error {
+	form := &FormData{}
+	if ev.Callback.FormsDataUrl != "" {
+		var err error
+		if form, err = h.client.DownloadFormData(ctx, ev.Callback.FormsDataUrl); err != nil {
+			return fmt.Errorf("download form data: %w", err)
+		}
+	}
+	return h.handlers.OnFormSubmit(ctx, ev, form)
+}
+
 // respondOK sends a successful response to OnlyOffice
 func (h *callbackHandler) respondOK(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
@@ -90,8 +435,12 @@ func (h *callbackHandler) respondOK(w http.ResponseWriter) {
 	json.NewEncoder(w).Encode(map[string]int{"error": 0})
 }
 
-// respondError sends an error response to OnlyOffice
-func (h *callbackHandler) respondError(w http.ResponseWriter, status int) {
+// respondError reports err and sends an error response to OnlyOffice
+func (h *callbackHandler) respondError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if h.reportError != nil {
+		h.reportError(r, status, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]int{"error": 1})
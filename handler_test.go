@@ -0,0 +1,132 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+func newTestClient(t *testing.T) *onlyoffice.Client {
+	t.Helper()
+
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{
+		DocumentServerURL: "https://example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func postCallback(h http.Handler, body string) *httptest.ResponseRecorder {
+	return postCallbackTo(h, "/callback?doc=42", body)
+}
+
+func postCallbackTo(h http.Handler, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCallbackHandlerDispatch(t *testing.T) {
+	client := newTestClient(t)
+
+	var plainKey, eventDoc string
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnEditing: func(cb *models.Callback) error {
+			plainKey = cb.Key
+			return nil
+		},
+		OnSave: func(cb *models.Callback) error {
+			t.Error("OnSave must not run when OnSaveEvent is set")
+			return nil
+		},
+		OnSaveEvent: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			if ctx == nil || ev.Request == nil || len(ev.Body) == 0 {
+				t.Error("Event is missing request metadata")
+			}
+			eventDoc = ev.Request.URL.Query().Get("doc")
+			return nil
+		},
+		OnCorruptEvent: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			return errors.New("boom")
+		},
+	})
+
+	if rec := postCallback(h, `{"status": 1, "key": "k1"}`); rec.Code != http.StatusOK || plainKey != "k1" {
+		t.Errorf("Editing: got %d, key %q", rec.Code, plainKey)
+	}
+	if rec := postCallback(h, `{"status": 2, "key": "k2"}`); rec.Code != http.StatusOK || eventDoc != "42" {
+		t.Errorf("Save: got %d, doc %q", rec.Code, eventDoc)
+	}
+	if rec := postCallback(h, `{"status": 7, "key": "k3"}`); rec.Code != http.StatusInternalServerError ||
+		!strings.Contains(rec.Body.String(), `"error":1`) {
+		t.Errorf("Corrupt: got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := postCallback(h, `{"status": 4, "key": "k4"}`); rec.Code != http.StatusOK ||
+		!strings.Contains(rec.Body.String(), `"error":0`) {
+		t.Errorf("Unhandled status: got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCallbackHandlerHardening(t *testing.T) {
+	client := newTestClient(t)
+
+	type report struct {
+		status int
+		err    error
+	}
+	var reports []report
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{},
+		onlyoffice.WithMaxBodySize(64),
+		onlyoffice.WithAllowedSources(netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("::1/128")),
+		onlyoffice.WithErrorReporter(func(r *http.Request, status int, err error) {
+			reports = append(reports, report{status, err})
+		}),
+	)
+
+	send := func(remote, contentType, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(body))
+		req.RemoteAddr = remote
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name        string
+		remote      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"Allowed", "192.0.2.10:1234", "application/json; charset=utf-8", `{"status": 4, "key": "k"}`, http.StatusOK},
+		{"AllowedIPv6", "[::1]:1234", "application/json", `{"status": 4, "key": "k"}`, http.StatusOK},
+		{"ForeignSource", "198.51.100.1:1234", "application/json", `{"status": 4, "key": "k"}`, http.StatusForbidden},
+		{"WrongContentType", "192.0.2.10:1234", "text/plain", `{"status": 4, "key": "k"}`, http.StatusUnsupportedMediaType},
+		{"TooLarge", "192.0.2.10:1234", "application/json", `{"status": 4, "key": "` + strings.Repeat("k", 100) + `"}`, http.StatusRequestEntityTooLarge},
+		{"Malformed", "192.0.2.10:1234", "application/json", `{"status":`, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports = nil
+			if got := send(tt.remote, tt.contentType, tt.body); got != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, got)
+			}
+			if tt.status != http.StatusOK && (len(reports) != 1 || reports[0].status != tt.status || reports[0].err == nil) {
+				t.Errorf("Expected the error to be reported, got %+v", reports)
+			}
+		})
+	}
+}
@@ -1,92 +1,636 @@
 package onlyoffice
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
 )
 
+// historyPrefix is the directory holding document history in a Storage
+const historyPrefix = ".history"
+
+// historyTimeFormat is the time format used by the Document Server history
+const historyTimeFormat = "2006-01-02 15:04:05"
+
+// ErrNoHistoryStore is returned by the history functions when neither
+// Config.History nor Config.Storage is set.
+var ErrNoHistoryStore = errors.New("onlyoffice: no history store configured")
+
+// ErrCorruptHistory is wrapped by the errors returned for history entries
+// whose metadata cannot be read
+var ErrCorruptHistory = errors.New("onlyoffice: corrupt history entry")
+
+// HistoryVersion is a single saved version of a document. Versions of a
+// document are numbered 1, 2, 3… in the order they were saved.
 type HistoryVersion struct {
-	Version     string          `json:"version"`
-	Key         string          `json:"key"`
-	Created     time.Time       `json:"created"`
-	User        *models.User    `json:"user"`
-	ChangesData []models.Change `json:"changes"`
+	Version       int             `json:"version"`
+	Key           string          `json:"key"`
+	Created       time.Time       `json:"created"`
+	User          *models.User    `json:"user,omitempty"`
+	ServerVersion string          `json:"serverVersion,omitempty"`
+	ChangesData   []models.Change `json:"changes"`
+	// FileType is the extension of the document kept with the version
+	FileType string `json:"fileType,omitempty"`
+	// Artifacts lists the files stored with the version
+	Artifacts []HistoryArtifact `json:"artifacts,omitempty"`
+	// RestoredFrom is the version whose file became current when this
+	// version was recorded by RestoreVersion
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+}
+
+// CheckHistoryVersion validates the fields of v that name stored files. The
+// version number must not be negative, and FileType may only hold lowercase
+// letters and digits. Stores check every version before writing it, as
+// imported versions come from untrusted sources.
+func CheckHistoryVersion(v *HistoryVersion) error {
+	if v.Version < 0 {
+		return fmt.Errorf("history: invalid version %d", v.Version)
+	}
+	if !validFileType(v.FileType) {
+		return fmt.Errorf("history: invalid file type %q", v.FileType)
+	}
+	return nil
+}
+
+// validFileType reports whether fileType is empty or a lowercase
+// alphanumeric extension
+func validFileType(fileType string) bool {
+	for _, r := range fileType {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
 }
 
-func (c *Client) CreateHistory(callback models.Callback, storagePath string) error {
-	historyDir := filepath.Join(storagePath, ".history", callback.Key)
-	if err := os.MkdirAll(historyDir, 0755); err != nil {
+// HistoryArtifact identifies a file kept with a history version
+type HistoryArtifact string
+
+const (
+	// ArtifactDocument is the document as it was before the save that
+	// created the version
+	ArtifactDocument HistoryArtifact = "document"
+	// ArtifactChanges is the changes.zip downloaded from the callback's
+	// changesurl, used by the editor to show the differences
+	ArtifactChanges HistoryArtifact = "changes"
+)
+
+// HistoryArtifacts holds the files stored with a new version. Nil readers
+// are skipped.
+type HistoryArtifacts struct {
+	Document io.Reader
+	Changes  io.Reader
+}
+
+// HistoryStore keeps the version history of documents. Documents are
+// identified by an application-defined ID, usually their storage name.
+type HistoryStore interface {
+	// AddVersion appends v with its artifacts to the history of the
+	// document and assigns it the next version number.
+	AddVersion(ctx context.Context, docID string, v *HistoryVersion, artifacts HistoryArtifacts) (*HistoryVersion, error)
+	// Versions returns the history of the document, oldest first.
+	Versions(ctx context.Context, docID string) ([]HistoryVersion, error)
+	// Version returns a single version of the document. It returns an error
+	// satisfying storage.IsNotExist when the version does not exist.
+	Version(ctx context.Context, docID string, version int) (*HistoryVersion, error)
+	// OpenArtifact opens a file stored with a version
+	OpenArtifact(ctx context.Context, docID string, version int, artifact HistoryArtifact) (io.ReadCloser, *storage.ObjectInfo, error)
+	// DeleteVersion removes a version together with its artifacts
+	DeleteVersion(ctx context.Context, docID string, version int) error
+	// DeleteArtifact removes a single file of a version. Removing an
+	// artifact that does not exist is not an error.
+	DeleteArtifact(ctx context.Context, docID string, version int, artifact HistoryArtifact) error
+}
+
+// HistoryImporter is implemented by history stores that can add a version
+// under a given number, as needed to import history without renumbering it
+type HistoryImporter interface {
+	// ImportVersion stores v under v.Version. It fails if the version
+	// exists.
+	ImportVersion(ctx context.Context, docID string, v HistoryVersion, artifacts HistoryArtifacts) error
+}
+
+// CreateHistory records a save callback as the next version of the document.
+// It must be called before the saved file replaces the current one: the
+// current document, read from Config.Storage under docID, is kept as the
+// version's file together with the changes.zip from the callback's
+// changesurl.
+func (c *Client) CreateHistory(docID string, callback models.Callback) (*HistoryVersion, error) {
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+	return c.recordVersion(context.Background(), store, c.config.Storage, docID, &callback)
+}
+
+// OpenHistoryArtifact opens a file stored with a version of the document
+func (c *Client) OpenHistoryArtifact(docID string, version int, artifact HistoryArtifact) (io.ReadCloser, *storage.ObjectInfo, error) {
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	return store.OpenArtifact(context.Background(), docID, version, artifact)
+}
+
+// recordVersion adds a version for a save callback to store. The current
+// document is read from st when st is not nil.
+func (c *Client) recordVersion(ctx context.Context, store HistoryStore, st storage.Storage, docID string, cb *models.Callback) (*HistoryVersion, error) {
+	v := NewHistoryVersion(*cb)
+	if ext := strings.ToLower(strings.TrimPrefix(path.Ext(docID), ".")); validFileType(ext) {
+		v.FileType = ext
+	}
+
+	var artifacts HistoryArtifacts
+	if st != nil {
+		doc, _, err := st.Get(ctx, docID)
+		switch {
+		case err == nil:
+			defer doc.Close()
+			artifacts.Document = doc
+		case !storage.IsNotExist(err):
+			return nil, fmt.Errorf("history: open current document: %w", err)
+		}
+	}
+
+	if cb.ChangesUrl != "" {
+		changes, err := c.openURL(ctx, cb.ChangesUrl)
+		if err != nil {
+			return nil, fmt.Errorf("history: download changes: %w", err)
+		}
+		defer changes.Close()
+		artifacts.Changes = changes
+	}
+
+	return store.AddVersion(ctx, docID, v, artifacts)
+}
+
+// GetHistory returns the versions of a document, oldest first
+func (c *Client) GetHistory(docID string) ([]HistoryVersion, error) {
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Versions(context.Background(), docID)
+}
+
+// CountVersion returns the number of versions of a document. Errors, such
+// as ErrCorruptHistory, are returned rather than counted as no versions.
+func (c *Client) CountVersion(docID string) (int, error) {
+	versions, err := c.GetHistory(docID)
+	if err != nil {
+		return 0, err
+	}
+	return len(versions), nil
+}
+
+// historyStore returns the configured history store
+func (c *Client) historyStore() (HistoryStore, error) {
+	if c.config.History != nil {
+		return c.config.History, nil
+	}
+	if c.config.Storage != nil {
+		return NewStorageHistory(c.config.Storage), nil
+	}
+	return nil, ErrNoHistoryStore
+}
+
+// NewHistoryVersion builds an unnumbered version from the history of a
+// callback. The author and creation time fall back to the last change when
+// the history does not carry them, the creation time then to the callback's
+// lastsave, and finally to the current time.
+func NewHistoryVersion(callback models.Callback) *HistoryVersion {
+	h := callback.History
+	v := &HistoryVersion{
+		Key:           callback.Key,
+		User:          h.User,
+		ServerVersion: h.ServerVersion,
+		ChangesData:   h.Changes,
+	}
+	if v.Key == "" {
+		v.Key = h.Key
+	}
+
+	if n := len(h.Changes); n > 0 && v.User == nil {
+		user := h.Changes[n-1].User
+		v.User = &user
+	}
+	if t, ok := callbackCreated(&callback); ok {
+		v.Created = t
+	} else {
+		v.Created = time.Now().UTC().Truncate(time.Second)
+	}
+
+	return v
+}
+
+// callbackCreated returns the creation time of the save a callback reports,
+// taken from its history, its last change or its lastsave. Retries of a save
+// report the same time.
+func callbackCreated(callback *models.Callback) (time.Time, bool) {
+	h := callback.History
+	created := h.Created
+	if n := len(h.Changes); n > 0 && created == "" {
+		created = h.Changes[n-1].Created
+	}
+	if t, err := time.Parse(historyTimeFormat, created); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, callback.LastSave); err == nil {
+		return t.UTC().Truncate(time.Second), true
+	}
+	return time.Time{}, false
+}
+
+// StorageHistory is a HistoryStore keeping the versions of a document in a
+// Storage, next to the documents themselves. It uses the layout of the
+// official integration examples:
+//
+//	.history/<docID>/<version>/changes.json  history metadata
+//	.history/<docID>/<version>/key.txt       document key of the version
+//	.history/<docID>/<version>/prev.<ext>    document as of the version
+//	.history/<docID>/<version>/diff.zip      changes.zip from changesurl
+//
+// Writes are serialized per document, across processes as well when the
+// Storage implements storage.Locker. changes.json is written last, so a
+// version interrupted by a crash stays invisible and its leftovers are
+// replaced by the next version.
+type StorageHistory struct {
+	storage storage.Storage
+}
+
+// historyLocks serializes history writes per document within the process
+var historyLocks keyedMutex
+
+// documentLocks serializes saves and restores per document within the
+// process, so a version and the document it replaces stay consistent
+var documentLocks keyedMutex
+
+// NewStorageHistory creates a history store on st
+func NewStorageHistory(st storage.Storage) *StorageHistory {
+	return &StorageHistory{storage: st}
+}
+
+// lock serializes writes to the history of a document
+func (s *StorageHistory) lock(ctx context.Context, docID string) (func(), error) {
+	unlock := historyLocks.lock(docID)
+
+	locker, ok := s.storage.(storage.Locker)
+	if !ok {
+		return unlock, nil
+	}
+	release, err := locker.Lock(ctx, historyDocDir(docID))
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("history: lock %s: %w", docID, err)
+	}
+	return func() {
+		release()
+		unlock()
+	}, nil
+}
+
+// AddVersion implements HistoryStore
+func (s *StorageHistory) AddVersion(ctx context.Context, docID string, v *HistoryVersion, artifacts HistoryArtifacts) (*HistoryVersion, error) {
+	stored := *v
+	stored.Version = 0
+	if err := s.add(ctx, docID, &stored, artifacts); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// ImportVersion implements HistoryImporter
+func (s *StorageHistory) ImportVersion(ctx context.Context, docID string, v HistoryVersion, artifacts HistoryArtifacts) error {
+	if v.Version < 1 {
+		return fmt.Errorf("history: invalid version %d", v.Version)
+	}
+	return s.add(ctx, docID, &v, artifacts)
+}
+
+// add stores a version. A zero version number is assigned the next one.
+func (s *StorageHistory) add(ctx context.Context, docID string, stored *HistoryVersion, artifacts HistoryArtifacts) error {
+	if err := checkDocID(docID); err != nil {
+		return err
+	}
+	if err := CheckHistoryVersion(stored); err != nil {
+		return err
+	}
+	unlock, err := s.lock(ctx, docID)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	changesFile := filepath.Join(historyDir, "changes.json")
-	data, err := json.MarshalIndent(callback.History, "", "  ")
+	dirs, err := s.scan(ctx, docID)
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(changesFile, data, 0644); err != nil {
+	if stored.Version == 0 {
+		stored.Version = 1
+		if n := len(dirs.complete); n > 0 {
+			stored.Version = dirs.complete[n-1] + 1
+		}
+	} else if slices.Contains(dirs.complete, stored.Version) {
+		return fmt.Errorf("history: version %d of %s already exists", stored.Version, docID)
+	}
+	stored.Artifacts = nil
+	dir := historyVersionDir(docID, stored.Version)
+
+	// Remove what an interrupted write left behind
+	for _, name := range dirs.files[stored.Version] {
+		if err := s.storage.Delete(ctx, name); err != nil && !storage.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if artifacts.Document != nil {
+		if _, err := s.storage.Put(ctx, path.Join(dir, prevName(stored.FileType)), artifacts.Document); err != nil {
+			return fmt.Errorf("history: store document: %w", err)
+		}
+		stored.Artifacts = append(stored.Artifacts, ArtifactDocument)
+	}
+	if artifacts.Changes != nil {
+		if _, err := s.storage.Put(ctx, path.Join(dir, "diff.zip"), artifacts.Changes); err != nil {
+			return fmt.Errorf("history: store changes: %w", err)
+		}
+		stored.Artifacts = append(stored.Artifacts, ArtifactChanges)
+	}
+	if _, err := s.storage.Put(ctx, path.Join(dir, "key.txt"), strings.NewReader(stored.Key)); err != nil {
+		return err
+	}
+
+	// changes.json is written last and marks the version as complete
+	data, err := json.MarshalIndent(historyRecord(stored), "", "  ")
+	if err != nil {
 		return err
 	}
+	_, err = s.storage.Put(ctx, path.Join(dir, "changes.json"), bytes.NewReader(data))
+	return err
+}
 
-	return nil
+// Versions implements HistoryStore. A version whose metadata cannot be read
+// fails the whole call with an error wrapping ErrCorruptHistory.
+func (s *StorageHistory) Versions(ctx context.Context, docID string) ([]HistoryVersion, error) {
+	dirs, err := s.scan(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]HistoryVersion, 0, len(dirs.complete))
+	for _, n := range dirs.complete {
+		v, err := s.Version(ctx, docID, n)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+	return versions, nil
 }
 
-func (c *Client) GetHistory(filename, storagePath string) ([]HistoryVersion, error) {
-	historyDir := filepath.Join(storagePath, ".history")
-	if _, err := os.Stat(historyDir); os.IsNotExist(err) {
-		return []HistoryVersion{}, nil
+// Version implements HistoryStore
+func (s *StorageHistory) Version(ctx context.Context, docID string, version int) (*HistoryVersion, error) {
+	if err := checkDocID(docID); err != nil {
+		return nil, err
+	}
+	r, _, err := s.storage.Get(ctx, path.Join(historyVersionDir(docID, version), "changes.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var history historyFile
+	if err := json.NewDecoder(r).Decode(&history); err != nil {
+		return nil, fmt.Errorf("history: version %d of %s: %w: %v", version, docID, ErrCorruptHistory, err)
+	}
+	if history.Version != 0 && history.Version != version {
+		return nil, fmt.Errorf("history: version %d of %s is recorded as %d: %w", version, docID, history.Version, ErrCorruptHistory)
 	}
 
-	files, err := os.ReadDir(historyDir)
+	v := versionFromRecord(history)
+	v.Version = version
+
+	objects, err := s.storage.List(ctx, historyVersionDir(docID, version)+"/")
 	if err != nil {
 		return nil, err
 	}
+	var hasDocument, hasChanges bool
+	for _, obj := range objects {
+		switch base := path.Base(obj.Name); {
+		case base == "prev" || strings.HasPrefix(base, "prev."):
+			v.FileType = strings.TrimPrefix(path.Ext(base), ".")
+			hasDocument = true
+		case base == "diff.zip":
+			hasChanges = true
+		}
+	}
+	if hasDocument {
+		v.Artifacts = append(v.Artifacts, ArtifactDocument)
+	}
+	if hasChanges {
+		v.Artifacts = append(v.Artifacts, ArtifactChanges)
+	}
+	return v, nil
+}
+
+// OpenArtifact implements HistoryStore
+func (s *StorageHistory) OpenArtifact(ctx context.Context, docID string, version int, artifact HistoryArtifact) (io.ReadCloser, *storage.ObjectInfo, error) {
+	v, err := s.Version(ctx, docID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, err := artifactName(v, artifact)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.storage.Get(ctx, path.Join(historyVersionDir(docID, version), name))
+}
 
-	var versions []HistoryVersion
-	for _, file := range files {
-		if file.IsDir() {
-			versionFile := filepath.Join(historyDir, file.Name(), "changes.json")
-			if data, err := os.ReadFile(versionFile); err == nil {
-				var history models.History
-				if err := json.Unmarshal(data, &history); err == nil {
-					created, _ := time.Parse("2006-01-02 15:04:05", history.Created)
-					version := HistoryVersion{
-						Version:     file.Name(),
-						Key:         history.Key,
-						Created:     created,
-						User:        history.User,
-						ChangesData: history.Changes,
-					}
-					versions = append(versions, version)
-				}
-			}
+// DeleteVersion implements HistoryStore
+func (s *StorageHistory) DeleteVersion(ctx context.Context, docID string, version int) error {
+	if err := checkDocID(docID); err != nil {
+		return err
+	}
+	unlock, err := s.lock(ctx, docID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := historyVersionDir(docID, version)
+	objects, err := s.storage.List(ctx, dir+"/")
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("history: version %d of %s: %w", version, docID, storage.ErrNotExist)
+	}
+
+	// changes.json goes first so that an interrupted delete never leaves a
+	// version that looks complete but lacks its files
+	metadata := path.Join(dir, "changes.json")
+	if err := s.storage.Delete(ctx, metadata); err != nil && !storage.IsNotExist(err) {
+		return err
+	}
+	for _, obj := range objects {
+		if obj.Name == metadata {
+			continue
+		}
+		if err := s.storage.Delete(ctx, obj.Name); err != nil && !storage.IsNotExist(err) {
+			return err
 		}
 	}
+	return nil
+}
 
-	return versions, nil
+// DeleteArtifact implements HistoryStore
+func (s *StorageHistory) DeleteArtifact(ctx context.Context, docID string, version int, artifact HistoryArtifact) error {
+	if err := checkDocID(docID); err != nil {
+		return err
+	}
+	unlock, err := s.lock(ctx, docID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v, err := s.Version(ctx, docID, version)
+	if err != nil {
+		return err
+	}
+
+	name, err := artifactName(v, artifact)
+	if err != nil {
+		return err
+	}
+	err = s.storage.Delete(ctx, path.Join(historyVersionDir(docID, version), name))
+	if err != nil && !storage.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// artifactName returns the file name of an artifact in a version directory
+func artifactName(v *HistoryVersion, artifact HistoryArtifact) (string, error) {
+	switch artifact {
+	case ArtifactDocument:
+		return prevName(v.FileType), nil
+	case ArtifactChanges:
+		return "diff.zip", nil
+	default:
+		return "", fmt.Errorf("history: unknown artifact %q", artifact)
+	}
 }
 
-func (c *Client) CountVersion(storagePath string) int {
-	historyDir := filepath.Join(storagePath, ".history")
-	if _, err := os.Stat(historyDir); os.IsNotExist(err) {
-		return 0
+// prevName returns the name of the document file of a version
+func prevName(fileType string) string {
+	if fileType == "" {
+		return "prev"
 	}
+	return "prev." + fileType
+}
+
+// historyDirs describes the version directories of a document
+type historyDirs struct {
+	complete []int            // versions with changes.json, in order
+	files    map[int][]string // objects per version directory
+}
 
-	files, err := os.ReadDir(historyDir)
+// scan lists the version directories of a document
+func (s *StorageHistory) scan(ctx context.Context, docID string) (*historyDirs, error) {
+	if err := checkDocID(docID); err != nil {
+		return nil, err
+	}
+	prefix := historyDocDir(docID) + "/"
+	objects, err := s.storage.List(ctx, prefix)
 	if err != nil {
-		return 0
+		return nil, err
 	}
 
-	count := 0
-	for _, file := range files {
-		if file.IsDir() {
-			count++
+	dirs := &historyDirs{files: make(map[int][]string)}
+	for _, obj := range objects {
+		dir, file, ok := strings.Cut(strings.TrimPrefix(obj.Name, prefix), "/")
+		if !ok {
+			continue
 		}
+		n, err := strconv.Atoi(dir)
+		if err != nil || n < 1 {
+			continue
+		}
+		dirs.files[n] = append(dirs.files[n], obj.Name)
+		if file == "changes.json" {
+			dirs.complete = append(dirs.complete, n)
+		}
+	}
+	sort.Ints(dirs.complete)
+	return dirs, nil
+}
+
+// checkDocID rejects document IDs that cannot name a history directory
+func checkDocID(docID string) error {
+	if docID == "" || docID == "." || docID == ".." {
+		return fmt.Errorf("history: invalid document id %q", docID)
+	}
+	return nil
+}
+
+// historyDocDir returns the history directory of a document. The ID is
+// escaped to a single path element so that nested names cannot collide.
+func historyDocDir(docID string) string {
+	return path.Join(historyPrefix, url.PathEscape(docID))
+}
+
+// historyVersionDir returns the directory of a single version
+func historyVersionDir(docID string, version int) string {
+	return path.Join(historyDocDir(docID), strconv.Itoa(version))
+}
+
+// historyFile is the content of changes.json: the Document Server history
+// format extended with the SDK's own fields
+type historyFile struct {
+	models.History
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+}
+
+// historyRecord converts a version to its changes.json record
+func historyRecord(v *HistoryVersion) historyFile {
+	return historyFile{
+		History: models.History{
+			Changes:       v.ChangesData,
+			ServerVersion: v.ServerVersion,
+			Created:       v.Created.Format(historyTimeFormat),
+			Key:           v.Key,
+			User:          v.User,
+			Version:       v.Version,
+		},
+		RestoredFrom: v.RestoredFrom,
+	}
+}
+
+// versionFromRecord converts a stored changes.json record to a version
+func versionFromRecord(h historyFile) *HistoryVersion {
+	created, _ := time.Parse(historyTimeFormat, h.Created)
+	return &HistoryVersion{
+		Version:       h.Version,
+		Key:           h.Key,
+		Created:       created,
+		User:          h.User,
+		ServerVersion: h.ServerVersion,
+		ChangesData:   h.Changes,
+		RestoredFrom:  h.RestoredFrom,
 	}
-	return count
 }
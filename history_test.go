@@ -0,0 +1,293 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func TestHistoryPerDocument(t *testing.T) {
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: storage.NewMemory()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	save := func(docID, key, user string) *onlyoffice.HistoryVersion {
+		t.Helper()
+		v, err := client.CreateHistory(docID, models.Callback{
+			Key:    key,
+			Status: models.StatusMustSave,
+			History: models.History{
+				ServerVersion: "7.3.0",
+				Changes: []models.Change{
+					{Created: "2026-01-21 15:52:27", User: models.User{Id: user, Name: user}},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateHistory(%s) failed: %v", docID, err)
+		}
+		return v
+	}
+
+	save("a.docx", "ka1", "u1")
+	save("dir/b.docx", "kb1", "u2")
+	v := save("a.docx", "ka2", "u2")
+	if v.Version != 2 {
+		t.Errorf("Expected version 2, got %d", v.Version)
+	}
+
+	versions, err := client.GetHistory("a.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+	for i, v := range versions {
+		if v.Version != i+1 {
+			t.Errorf("Version %d numbered %d", i+1, v.Version)
+		}
+	}
+	last := versions[1]
+	if last.Key != "ka2" || last.ServerVersion != "7.3.0" || last.User == nil || last.User.Id != "u2" {
+		t.Errorf("Unexpected version %+v", last)
+	}
+	if last.Created.Format("2006-01-02 15:04:05") != "2026-01-21 15:52:27" || len(last.ChangesData) != 1 {
+		t.Errorf("Unexpected version %+v", last)
+	}
+
+	if n, err := client.CountVersion("dir/b.docx"); err != nil || n != 1 {
+		t.Errorf("Expected 1 version of dir/b.docx, got %d, %v", n, err)
+	}
+	if n, err := client.CountVersion("missing.docx"); err != nil || n != 0 {
+		t.Errorf("Expected no versions, got %d, %v", n, err)
+	}
+
+	if _, err := client.GetHistory(".."); err == nil {
+		t.Error("Expected error for invalid document id")
+	}
+}
+
+func TestHistoryNotConfigured(t *testing.T) {
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateHistory("a.docx", models.Callback{Key: "k"}); err != onlyoffice.ErrNoHistoryStore {
+		t.Errorf("Expected ErrNoHistoryStore, got %v", err)
+	}
+}
+
+func TestHistoryArtifacts(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("changes"))
+	}))
+	defer files.Close()
+
+	st := storage.NewMemory()
+	ctx := context.Background()
+	if _, err := st.Put(ctx, "docs/a.docx", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := client.CreateHistory("docs/a.docx", models.Callback{
+		Key:        "k1",
+		Status:     models.StatusMustSave,
+		ChangesUrl: files.URL + "/changes.zip",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 1 || v.FileType != "docx" || len(v.Artifacts) != 2 {
+		t.Fatalf("Unexpected version %+v", v)
+	}
+
+	// A version without a previous document or changes keeps only metadata
+	if err := st.Delete(ctx, "docs/a.docx"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateHistory("docs/a.docx", models.Callback{Key: "k2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := client.GetHistory("docs/a.docx")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d (%v)", len(versions), err)
+	}
+	if got := versions[0]; got.Key != "k1" || got.FileType != "docx" || len(got.Artifacts) != 2 {
+		t.Errorf("Unexpected version %+v", got)
+	}
+	if got := versions[1]; len(got.Artifacts) != 0 {
+		t.Errorf("Expected no artifacts, got %v", got.Artifacts)
+	}
+
+	for artifact, want := range map[onlyoffice.HistoryArtifact]string{
+		onlyoffice.ArtifactDocument: "first",
+		onlyoffice.ArtifactChanges:  "changes",
+	} {
+		r, _, err := client.OpenHistoryArtifact("docs/a.docx", 1, artifact)
+		if err != nil {
+			t.Errorf("Failed to open %s: %v", artifact, err)
+			continue
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", artifact, data, want)
+		}
+	}
+
+	if _, _, err := client.OpenHistoryArtifact("docs/a.docx", 2, onlyoffice.ArtifactDocument); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+	if _, _, err := client.OpenHistoryArtifact("docs/a.docx", 3, onlyoffice.ArtifactChanges); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
+
+func TestHistoryConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+
+	// Separate clients on the same directory behave like separate processes
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: storage.NewFS(dir)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := client.CreateHistory("a.docx", models.Callback{Key: fmt.Sprintf("k%d", i)})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, _ := onlyoffice.NewClient(&onlyoffice.Config{Storage: storage.NewFS(dir)})
+	versions, err := client.GetHistory("a.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]bool)
+	for i, v := range versions {
+		if v.Version != i+1 {
+			t.Errorf("Version %d numbered %d", i+1, v.Version)
+		}
+		keys[v.Key] = true
+	}
+	if len(versions) != 20 || len(keys) != 20 {
+		t.Errorf("Expected 20 distinct versions, got %d with %d keys", len(versions), len(keys))
+	}
+}
+
+func TestHistoryCorruptAndIncomplete(t *testing.T) {
+	dir := t.TempDir()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: storage.NewFS(dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateHistory("a.docx", models.Callback{Key: "k1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A version interrupted before changes.json is invisible and replaced
+	leftover := filepath.Join(dir, ".history", "a.docx", "2", "diff.zip")
+	if err := os.MkdirAll(filepath.Dir(leftover), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(leftover, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := client.CountVersion("a.docx"); err != nil || n != 1 {
+		t.Errorf("Expected 1 version, got %d, %v", n, err)
+	}
+	v, err := client.CreateHistory("a.docx", models.Callback{Key: "k2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 2 || len(v.Artifacts) != 0 {
+		t.Errorf("Unexpected version %+v", v)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("Expected leftover to be removed: %v", err)
+	}
+
+	// A truncated changes.json is reported instead of skipped
+	metadata := filepath.Join(dir, ".history", "a.docx", "1", "changes.json")
+	if err := os.WriteFile(metadata, []byte(`{"key": "k1", "chan`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetHistory("a.docx"); !errors.Is(err, onlyoffice.ErrCorruptHistory) {
+		t.Errorf("Expected ErrCorruptHistory, got %v", err)
+	}
+	if _, err := client.CountVersion("a.docx"); !errors.Is(err, onlyoffice.ErrCorruptHistory) {
+		t.Errorf("Expected CountVersion to report ErrCorruptHistory, got %v", err)
+	}
+}
+
+func TestMigrateLegacyHistory(t *testing.T) {
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Earlier releases stored .history/<key>/changes.json in the storage
+	// directory of the document
+	ctx := context.Background()
+	legacy := map[string]string{
+		".history/k2/changes.json": `{"key": "k2", "created": "2026-01-21 16:00:00", "user": {"id": "u2"}, "changes": [{"created": "2026-01-21 16:00:00", "user": {"id": "u2"}}]}`,
+		".history/k1/changes.json": `{"key": "k1", "created": "2026-01-21 15:52:27", "user": {"id": "u1"}, "serverVersion": "7.3.0"}`,
+	}
+	for name, content := range legacy {
+		if _, err := st.Put(ctx, name, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := client.MigrateLegacyHistory(st, "a.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Key != "k1" || versions[1].Key != "k2" || versions[1].Version != 2 {
+		t.Fatalf("Unexpected migrated versions %+v", versions)
+	}
+
+	history, err := client.GetHistory("a.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].User.Id != "u1" || history[0].ServerVersion != "7.3.0" {
+		t.Errorf("Unexpected history %+v", history)
+	}
+
+	// The legacy entries are gone, so migrating again does nothing
+	if versions, err := client.MigrateLegacyHistory(st, "a.docx"); err != nil || len(versions) != 0 {
+		t.Errorf("Expected nothing left to migrate, got %v, %v", versions, err)
+	}
+}
@@ -0,0 +1,521 @@
+// Package historygit provides an onlyoffice.HistoryStore that keeps the
+// history of each document in its own bare git repository. Every change to
+// the history is a commit, so the repository doubles as an audit trail that
+// can be inspected with standard git tooling.
+//
+// The tree of the main branch mirrors onlyoffice.StorageHistory:
+//
+//	<version>/version.json  version metadata
+//	<version>/prev.<ext>    document as of the version
+//	<version>/diff.zip      changes.zip from changesurl
+//
+// Saved versions are committed with the author and time of the version and
+// a message carrying the server version and change count. Deleting versions
+// or artifacts commits their removal; earlier commits keep the content.
+// The store runs the git command line tool.
+package historygit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// branch is the ref holding the history
+const branch = "refs/heads/main"
+
+// Options configures a Store
+type Options struct {
+	// Git is the git executable, default "git"
+	Git string
+	// CommitterName and CommitterEmail identify the committer of every
+	// commit. The author is the user of the version.
+	CommitterName  string
+	CommitterEmail string
+}
+
+// Store is an onlyoffice.HistoryStore with one bare git repository per
+// document below a root directory. Writes are serialized within the process
+// and guarded across processes by a compare-and-swap update of the branch.
+type Store struct {
+	root string
+	opts Options
+	mu   sync.Mutex
+}
+
+// New creates a store keeping repositories below root
+func New(root string, opts Options) *Store {
+	if opts.Git == "" {
+		opts.Git = "git"
+	}
+	if opts.CommitterName == "" {
+		opts.CommitterName = "go-onlyoffice"
+	}
+	if opts.CommitterEmail == "" {
+		opts.CommitterEmail = "go-onlyoffice@localhost"
+	}
+	return &Store{root: root, opts: opts}
+}
+
+// Repository returns the path of the repository of a document
+func (s *Store) Repository(docID string) string {
+	return filepath.Join(s.root, url.PathEscape(docID)+".git")
+}
+
+// AddVersion implements onlyoffice.HistoryStore
+func (s *Store) AddVersion(ctx context.Context, docID string, v *onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) (*onlyoffice.HistoryVersion, error) {
+	stored := *v
+	stored.Version = 0
+	if err := s.add(ctx, docID, &stored, artifacts); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// ImportVersion implements onlyoffice.HistoryImporter
+func (s *Store) ImportVersion(ctx context.Context, docID string, v onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) error {
+	if v.Version < 1 {
+		return fmt.Errorf("historygit: invalid version %d", v.Version)
+	}
+	return s.add(ctx, docID, &v, artifacts)
+}
+
+// add commits a version. A zero version number is assigned the next one.
+func (s *Store) add(ctx context.Context, docID string, stored *onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) error {
+	if docID == "" || docID == "." || docID == ".." {
+		return fmt.Errorf("historygit: invalid document id %q", docID)
+	}
+	if err := onlyoffice.CheckHistoryVersion(stored); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.Repository(docID)
+	if err := s.init(ctx, repo); err != nil {
+		return err
+	}
+	files, err := s.tree(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	numbers := versionNumbers(files)
+	if stored.Version == 0 {
+		stored.Version = 1
+		if len(numbers) > 0 {
+			stored.Version = numbers[len(numbers)-1] + 1
+		}
+	} else if slices.Contains(numbers, stored.Version) {
+		return fmt.Errorf("historygit: version %d of %s already exists", stored.Version, docID)
+	}
+	stored.Artifacts = nil
+	if stored.Created.IsZero() {
+		stored.Created = time.Now().UTC().Truncate(time.Second)
+	}
+	dir := strconv.Itoa(stored.Version)
+
+	add := make(map[string]string)
+	if artifacts.Document != nil {
+		name := "prev"
+		if stored.FileType != "" {
+			name += "." + stored.FileType
+		}
+		if add[path.Join(dir, name)], err = s.hashObject(ctx, repo, artifacts.Document); err != nil {
+			return err
+		}
+		stored.Artifacts = append(stored.Artifacts, onlyoffice.ArtifactDocument)
+	}
+	if artifacts.Changes != nil {
+		if add[path.Join(dir, "diff.zip")], err = s.hashObject(ctx, repo, artifacts.Changes); err != nil {
+			return err
+		}
+		stored.Artifacts = append(stored.Artifacts, onlyoffice.ArtifactChanges)
+	}
+
+	meta := *stored
+	meta.Artifacts = nil
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if add[path.Join(dir, "version.json")], err = s.hashObject(ctx, repo, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Version %d of %s\n\nServer-Version: %s\nChanges: %d\nKey: %s\n",
+		stored.Version, docID, stored.ServerVersion, len(stored.ChangesData), stored.Key)
+	if stored.RestoredFrom > 0 {
+		msg += fmt.Sprintf("Restored-From: %d\n", stored.RestoredFrom)
+	}
+
+	author := []string{"GIT_AUTHOR_NAME=unknown", "GIT_AUTHOR_EMAIL=unknown"}
+	if u := stored.User; u != nil {
+		name, email := u.Name, u.Email
+		if name == "" {
+			name = u.Id
+		}
+		if email == "" {
+			email = u.Id
+		}
+		author = []string{"GIT_AUTHOR_NAME=" + name, "GIT_AUTHOR_EMAIL=" + email}
+	}
+	author = append(author, fmt.Sprintf("GIT_AUTHOR_DATE=@%d +0000", stored.Created.Unix()))
+
+	return s.commit(ctx, repo, add, nil, msg, author)
+}
+
+// Versions implements onlyoffice.HistoryStore
+func (s *Store) Versions(ctx context.Context, docID string) ([]onlyoffice.HistoryVersion, error) {
+	files, err := s.tree(ctx, s.Repository(docID))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []onlyoffice.HistoryVersion{}
+	for _, n := range versionNumbers(files) {
+		v, err := s.version(ctx, docID, n, files)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+	return versions, nil
+}
+
+// Version implements onlyoffice.HistoryStore
+func (s *Store) Version(ctx context.Context, docID string, version int) (*onlyoffice.HistoryVersion, error) {
+	files, err := s.tree(ctx, s.Repository(docID))
+	if err != nil {
+		return nil, err
+	}
+	return s.version(ctx, docID, version, files)
+}
+
+// version reads a version from the files of the branch
+func (s *Store) version(ctx context.Context, docID string, version int, files map[string]treeEntry) (*onlyoffice.HistoryVersion, error) {
+	dir := strconv.Itoa(version)
+	meta, ok := files[path.Join(dir, "version.json")]
+	if !ok {
+		return nil, fmt.Errorf("historygit: version %d of %s: %w", version, docID, storage.ErrNotExist)
+	}
+
+	data, err := s.git(ctx, s.Repository(docID), nil, nil, "cat-file", "blob", meta.hash)
+	if err != nil {
+		return nil, err
+	}
+	var v onlyoffice.HistoryVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("historygit: version %d of %s: %w: %v", version, docID, onlyoffice.ErrCorruptHistory, err)
+	}
+	v.Version = version
+
+	v.Artifacts = nil
+	if name, ok := documentFile(files, dir); ok {
+		v.FileType = strings.TrimPrefix(path.Ext(name), ".")
+		v.Artifacts = append(v.Artifacts, onlyoffice.ArtifactDocument)
+	}
+	if _, ok := files[path.Join(dir, "diff.zip")]; ok {
+		v.Artifacts = append(v.Artifacts, onlyoffice.ArtifactChanges)
+	}
+	return &v, nil
+}
+
+// OpenArtifact implements onlyoffice.HistoryStore
+func (s *Store) OpenArtifact(ctx context.Context, docID string, version int, artifact onlyoffice.HistoryArtifact) (io.ReadCloser, *storage.ObjectInfo, error) {
+	repo := s.Repository(docID)
+	files, err := s.tree(ctx, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, ok := artifactFile(files, strconv.Itoa(version), artifact)
+	if !ok {
+		return nil, nil, fmt.Errorf("historygit: %s of version %d of %s: %w", artifact, version, docID, storage.ErrNotExist)
+	}
+	entry := files[name]
+
+	cmd := exec.CommandContext(ctx, s.opts.Git, "cat-file", "blob", entry.hash)
+	cmd.Env = append(os.Environ(), "GIT_DIR="+repo)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	info := &storage.ObjectInfo{Name: name, Size: entry.size, ETag: `"` + entry.hash + `"`}
+	return &blobReader{ReadCloser: stdout, cmd: cmd}, info, nil
+}
+
+// DeleteVersion implements onlyoffice.HistoryStore
+func (s *Store) DeleteVersion(ctx context.Context, docID string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.Repository(docID)
+	files, err := s.tree(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	prefix := strconv.Itoa(version) + "/"
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			remove = append(remove, name)
+		}
+	}
+	if len(remove) == 0 {
+		return fmt.Errorf("historygit: version %d of %s: %w", version, docID, storage.ErrNotExist)
+	}
+	return s.commit(ctx, repo, nil, remove, fmt.Sprintf("Delete version %d of %s\n", version, docID), nil)
+}
+
+// DeleteArtifact implements onlyoffice.HistoryStore
+func (s *Store) DeleteArtifact(ctx context.Context, docID string, version int, artifact onlyoffice.HistoryArtifact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo := s.Repository(docID)
+	files, err := s.tree(ctx, repo)
+	if err != nil {
+		return err
+	}
+	name, ok := artifactFile(files, strconv.Itoa(version), artifact)
+	if !ok {
+		return nil
+	}
+	return s.commit(ctx, repo, nil, []string{name}, fmt.Sprintf("Delete %s of version %d of %s\n", artifact, version, docID), nil)
+}
+
+// treeEntry is a file on the branch
+type treeEntry struct {
+	hash string
+	size int64
+}
+
+// tree lists the files on the branch. A missing repository or branch has
+// no files.
+func (s *Store) tree(ctx context.Context, repo string) (map[string]treeEntry, error) {
+	files := make(map[string]treeEntry)
+	if _, err := os.Stat(repo); errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	head, err := s.head(ctx, repo)
+	if err != nil || head == "" {
+		return files, err
+	}
+
+	out, err := s.git(ctx, repo, nil, nil, "ls-tree", "-r", "-l", "-z", head)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		files[name] = treeEntry{hash: fields[2], size: size}
+	}
+	return files, nil
+}
+
+// head returns the commit of the branch, or "" before the first commit
+func (s *Store) head(ctx context.Context, repo string) (string, error) {
+	out, err := s.git(ctx, repo, nil, nil, "for-each-ref", "--format=%(objectname)", branch)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// init creates the repository of a document if needed
+func (s *Store) init(ctx context.Context, repo string) error {
+	if _, err := os.Stat(repo); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(repo), 0755); err != nil {
+		return err
+	}
+	if _, err := s.git(ctx, "", nil, nil, "init", "--bare", "--quiet", repo); err != nil {
+		return err
+	}
+	_, err := s.git(ctx, repo, nil, nil, "symbolic-ref", "HEAD", branch)
+	return err
+}
+
+// hashObject stores a blob and returns its hash
+func (s *Store) hashObject(ctx context.Context, repo string, r io.Reader) (string, error) {
+	out, err := s.git(ctx, repo, nil, r, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// commit adds and removes files on the branch in a single commit
+func (s *Store) commit(ctx context.Context, repo string, add map[string]string, remove []string, msg string, author []string) error {
+	tmp, err := os.MkdirTemp("", "historygit-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// The index and an empty work tree live in a temporary directory, so
+	// the repository itself stays bare
+	env := []string{
+		"GIT_INDEX_FILE=" + filepath.Join(tmp, "index"),
+		"GIT_WORK_TREE=" + tmp,
+		"GIT_COMMITTER_NAME=" + s.opts.CommitterName,
+		"GIT_COMMITTER_EMAIL=" + s.opts.CommitterEmail,
+	}
+	if author == nil {
+		author = []string{"GIT_AUTHOR_NAME=" + s.opts.CommitterName, "GIT_AUTHOR_EMAIL=" + s.opts.CommitterEmail}
+	}
+	env = append(env, author...)
+
+	parent, err := s.head(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if parent != "" {
+		if _, err := s.git(ctx, repo, env, nil, "read-tree", parent); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(add))
+	for name := range add {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := s.git(ctx, repo, env, nil, "update-index", "--add", "--cacheinfo", "100644,"+add[name]+","+name); err != nil {
+			return err
+		}
+	}
+	for _, name := range remove {
+		if _, err := s.git(ctx, repo, env, nil, "update-index", "--force-remove", name); err != nil {
+			return err
+		}
+	}
+
+	tree, err := s.git(ctx, repo, env, nil, "write-tree")
+	if err != nil {
+		return err
+	}
+	args := []string{"commit-tree", strings.TrimSpace(string(tree)), "-m", msg}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	commit, err := s.git(ctx, repo, env, nil, args...)
+	if err != nil {
+		return err
+	}
+
+	// The expected old value makes a concurrent writer fail instead of
+	// losing its commit
+	old := parent
+	if old == "" {
+		old = strings.Repeat("0", 40)
+	}
+	_, err = s.git(ctx, repo, env, nil, "update-ref", "-m", strings.SplitN(msg, "\n", 2)[0], branch, strings.TrimSpace(string(commit)), old)
+	return err
+}
+
+// git runs a git command and returns its output
+func (s *Store) git(ctx context.Context, repo string, env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, s.opts.Git, args...)
+	cmd.Env = append(os.Environ(), env...)
+	if repo != "" {
+		cmd.Env = append(cmd.Env, "GIT_DIR="+repo)
+	}
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("historygit: git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// blobReader streams a blob from git cat-file
+type blobReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+// Close implements io.Closer
+func (r *blobReader) Close() error {
+	r.ReadCloser.Close()
+	r.cmd.Wait()
+	return nil
+}
+
+// versionNumbers returns the complete versions on the branch in order
+func versionNumbers(files map[string]treeEntry) []int {
+	var numbers []int
+	for name := range files {
+		dir, file, ok := strings.Cut(name, "/")
+		if !ok || file != "version.json" {
+			continue
+		}
+		if n, err := strconv.Atoi(dir); err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// documentFile returns the document file of a version directory
+func documentFile(files map[string]treeEntry, dir string) (string, bool) {
+	for name := range files {
+		base := strings.TrimPrefix(name, dir+"/")
+		if base != name && (base == "prev" || strings.HasPrefix(base, "prev.")) && !strings.Contains(base, "/") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// artifactFile returns the file of an artifact in a version directory
+func artifactFile(files map[string]treeEntry, dir string, artifact onlyoffice.HistoryArtifact) (string, bool) {
+	switch artifact {
+	case onlyoffice.ArtifactDocument:
+		return documentFile(files, dir)
+	case onlyoffice.ArtifactChanges:
+		name := path.Join(dir, "diff.zip")
+		_, ok := files[name]
+		return name, ok
+	}
+	return "", false
+}
@@ -0,0 +1,137 @@
+package historygit_test
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/historygit"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func newStore(t *testing.T) *historygit.Store {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	return historygit.New(t.TempDir(), historygit.Options{})
+}
+
+func TestStore(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+
+	alice := &models.User{Id: "u1", Name: "Alice", Email: "alice@example.com"}
+	created := time.Date(2026, 1, 21, 15, 52, 27, 0, time.UTC)
+	v, err := store.AddVersion(ctx, "docs/a.docx", &onlyoffice.HistoryVersion{
+		Key:           "k1",
+		Created:       created,
+		User:          alice,
+		ServerVersion: "7.3.0",
+		FileType:      "docx",
+		ChangesData:   []models.Change{{Created: "2026-01-21 15:52:27", User: *alice}},
+	}, onlyoffice.HistoryArtifacts{
+		Document: strings.NewReader("first"),
+		Changes:  strings.NewReader("changes"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 1 || len(v.Artifacts) != 2 {
+		t.Fatalf("Unexpected version %+v", v)
+	}
+	if _, err := store.AddVersion(ctx, "docs/a.docx", &onlyoffice.HistoryVersion{Key: "k2"}, onlyoffice.HistoryArtifacts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := store.Versions(ctx, "docs/a.docx")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d (%v)", len(versions), err)
+	}
+	got := versions[0]
+	if got.Key != "k1" || !got.Created.Equal(created) || got.User.Name != "Alice" || got.FileType != "docx" || len(got.ChangesData) != 1 || len(got.Artifacts) != 2 {
+		t.Errorf("Unexpected version %+v", got)
+	}
+
+	r, info, err := store.OpenArtifact(ctx, "docs/a.docx", 1, onlyoffice.ArtifactDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "first" || info.Size != 5 {
+		t.Errorf("Document = %q (%+v)", data, info)
+	}
+
+	// Every save is a commit by the version's author at the version's time
+	out, err := exec.Command("git", "--git-dir", store.Repository("docs/a.docx"), "log", "--reverse", "--format=%an <%ae> %at%n%B").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(out)
+	for _, want := range []string{"Alice <alice@example.com> 1769010747", "Version 1 of docs/a.docx", "Server-Version: 7.3.0", "Changes: 1", "Version 2 of docs/a.docx"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("git log lacks %q:\n%s", want, log)
+		}
+	}
+
+	if err := store.DeleteArtifact(ctx, "docs/a.docx", 1, onlyoffice.ArtifactChanges); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteVersion(ctx, "docs/a.docx", 2); err != nil {
+		t.Fatal(err)
+	}
+	versions, err = store.Versions(ctx, "docs/a.docx")
+	if err != nil || len(versions) != 1 || len(versions[0].Artifacts) != 1 {
+		t.Errorf("Unexpected versions after delete %+v (%v)", versions, err)
+	}
+	if err := store.DeleteVersion(ctx, "docs/a.docx", 2); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+	if _, err := store.Version(ctx, "missing.docx", 1); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
+
+func TestRestoreFromGit(t *testing.T) {
+	store := newStore(t)
+	docs := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: docs, History: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, content := range []string{"v1", "v2"} {
+		if _, err := docs.Put(ctx, "a.docx", strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.CreateHistory("a.docx", models.Callback{Key: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := client.RestoreVersion("a.docx", 1, onlyoffice.RestoreOptions{User: &models.User{Id: "u2", Name: "Bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version.Version != 3 || res.Version.RestoredFrom != 1 {
+		t.Errorf("Unexpected restore version %+v", res.Version)
+	}
+
+	r, _, err := docs.Get(ctx, "a.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "v1" {
+		t.Errorf("Current document = %q, want v1", data)
+	}
+}
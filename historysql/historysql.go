@@ -0,0 +1,570 @@
+// Package historysql provides an onlyoffice.HistoryStore on database/sql.
+// Version metadata, change entries and users live in tables that can be
+// queried directly; the artifact files are kept in a storage.Storage and
+// referenced by name and checksum.
+//
+// The schema is portable SQL and is created by Store.Migrate. It is tested
+// against SQLite; PostgreSQL and MySQL work with the matching Dialect.
+package historysql
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// changeTimeFormat is the time format of models.Change
+const changeTimeFormat = "2006-01-02 15:04:05"
+
+// Dialect selects the placeholder style of the database
+type Dialect int
+
+const (
+	// Question uses ? placeholders, as SQLite and MySQL do
+	Question Dialect = iota
+	// Dollar uses $1, $2… placeholders, as PostgreSQL does
+	Dollar
+)
+
+// Options configures a Store
+type Options struct {
+	Dialect Dialect
+	// Blobs holds the artifact files. Without it only metadata is stored
+	// and versions with artifacts are rejected.
+	Blobs storage.Storage
+	// Prefix is the directory of the artifact files in Blobs, default
+	// ".history"
+	Prefix string
+}
+
+// Store is an onlyoffice.HistoryStore on database/sql. Writes are serialized
+// within the process; across processes a conflicting version number fails
+// the write instead of overwriting a version.
+type Store struct {
+	db      *sql.DB
+	blobs   storage.Storage
+	dialect Dialect
+	prefix  string
+	mu      sync.Mutex
+}
+
+// DocumentVersion is a version together with the document it belongs to
+type DocumentVersion struct {
+	DocID string `json:"docId"`
+	onlyoffice.HistoryVersion
+}
+
+// Document summarizes the history of a document
+type Document struct {
+	DocID         string    `json:"docId"`
+	Versions      int       `json:"versions"`
+	LatestVersion int       `json:"latestVersion"`
+	Updated       time.Time `json:"updated"`
+}
+
+// New creates a store on db. Call Migrate before first use.
+func New(db *sql.DB, opts Options) *Store {
+	if opts.Prefix == "" {
+		opts.Prefix = ".history"
+	}
+	return &Store{db: db, blobs: opts.Blobs, dialect: opts.Dialect, prefix: opts.Prefix}
+}
+
+// AddVersion implements onlyoffice.HistoryStore
+func (s *Store) AddVersion(ctx context.Context, docID string, v *onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) (*onlyoffice.HistoryVersion, error) {
+	stored := *v
+	stored.Version = 0
+	if err := s.insert(ctx, docID, &stored, artifacts); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// ImportVersion stores a version under its own number, for example one read
+// from another HistoryStore. Data recorded as models.History converts with
+// onlyoffice.NewHistoryVersion. Importing an existing version fails.
+func (s *Store) ImportVersion(ctx context.Context, docID string, v onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) error {
+	if v.Version < 1 {
+		return fmt.Errorf("historysql: invalid version %d", v.Version)
+	}
+	return s.insert(ctx, docID, &v, artifacts)
+}
+
+// Import copies the history of a document from src, including artifacts.
+// Versions already present are skipped. It returns the number of versions
+// imported.
+func (s *Store) Import(ctx context.Context, src onlyoffice.HistoryStore, docID string) (int, error) {
+	versions, err := src.Versions(ctx, docID)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, v := range versions {
+		if _, err := s.Version(ctx, docID, v.Version); err == nil {
+			continue
+		} else if !storage.IsNotExist(err) {
+			return imported, err
+		}
+
+		if err := s.importVersion(ctx, src, docID, v); err != nil {
+			return imported, fmt.Errorf("historysql: import version %d of %s: %w", v.Version, docID, err)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// importVersion copies a single version from src
+func (s *Store) importVersion(ctx context.Context, src onlyoffice.HistoryStore, docID string, v onlyoffice.HistoryVersion) error {
+	var artifacts onlyoffice.HistoryArtifacts
+	for _, artifact := range v.Artifacts {
+		r, _, err := src.OpenArtifact(ctx, docID, v.Version, artifact)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		switch artifact {
+		case onlyoffice.ArtifactDocument:
+			artifacts.Document = r
+		case onlyoffice.ArtifactChanges:
+			artifacts.Changes = r
+		}
+	}
+	return s.ImportVersion(ctx, docID, v, artifacts)
+}
+
+// insert stores a version. A zero version number is assigned the next one.
+func (s *Store) insert(ctx context.Context, docID string, v *onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) error {
+	if docID == "" {
+		return errors.New("historysql: empty document id")
+	}
+	if err := onlyoffice.CheckHistoryVersion(v); err != nil {
+		return err
+	}
+	if s.blobs == nil && (artifacts.Document != nil || artifacts.Changes != nil) {
+		return errors.New("historysql: no blob storage for artifacts")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var written []string
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		if v.Version == 0 {
+			err := tx.QueryRowContext(ctx, s.rebind(`SELECT COALESCE(MAX(version), 0) + 1 FROM history_versions WHERE doc_id = ?`), docID).Scan(&v.Version)
+			if err != nil {
+				return err
+			}
+		} else {
+			var n int
+			err := tx.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM history_versions WHERE doc_id = ? AND version = ?`), docID, v.Version).Scan(&n)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("historysql: version %d of %s already exists", v.Version, docID)
+			}
+		}
+
+		v.Artifacts = nil
+		for _, a := range []struct {
+			artifact onlyoffice.HistoryArtifact
+			r        io.Reader
+		}{
+			{onlyoffice.ArtifactDocument, artifacts.Document},
+			{onlyoffice.ArtifactChanges, artifacts.Changes},
+		} {
+			if a.r == nil {
+				continue
+			}
+			name, err := s.objectName(docID, v, a.artifact)
+			if err != nil {
+				return err
+			}
+			h := sha256.New()
+			info, err := s.blobs.Put(ctx, name, io.TeeReader(a.r, h))
+			if err != nil {
+				return fmt.Errorf("store %s: %w", a.artifact, err)
+			}
+			written = append(written, name)
+
+			_, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO history_artifacts (doc_id, version, artifact, object_name, size, sha256) VALUES (?, ?, ?, ?, ?, ?)`),
+				docID, v.Version, string(a.artifact), name, info.Size, hex.EncodeToString(h.Sum(nil)))
+			if err != nil {
+				return err
+			}
+			v.Artifacts = append(v.Artifacts, a.artifact)
+		}
+
+		var userID, userData sql.NullString
+		if v.User != nil {
+			data, err := s.saveUser(ctx, tx, v.User)
+			if err != nil {
+				return err
+			}
+			userData = sql.NullString{String: data, Valid: true}
+			userID = sql.NullString{String: v.User.Id, Valid: v.User.Id != ""}
+		}
+		_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO history_versions (doc_id, version, doc_key, created_at, user_id, server_version, file_type, restored_from, user_data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			docID, v.Version, v.Key, v.Created.Unix(), userID, v.ServerVersion, v.FileType, v.RestoredFrom, userData)
+		if err != nil {
+			return err
+		}
+
+		for i, change := range v.ChangesData {
+			data, err := s.saveUser(ctx, tx, &change.User)
+			if err != nil {
+				return err
+			}
+			var created int64
+			if t, err := time.Parse(changeTimeFormat, change.Created); err == nil {
+				created = t.Unix()
+			}
+			_, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO history_changes (doc_id, version, seq, created_at, user_id, user_data) VALUES (?, ?, ?, ?, ?, ?)`),
+				docID, v.Version, i, created, change.User.Id, data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, name := range written {
+			s.blobs.Delete(ctx, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// saveUser returns the snapshot of a user stored with a version or change
+// entry, and records it as the latest profile of users with an id
+func (s *Store) saveUser(ctx context.Context, tx *sql.Tx, u *models.User) (string, error) {
+	data, err := json.Marshal(u)
+	if err != nil || u.Id == "" {
+		return string(data), err
+	}
+
+	var n int
+	if err := tx.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM history_users WHERE id = ?`), u.Id).Scan(&n); err != nil {
+		return "", err
+	}
+	if n > 0 {
+		_, err = tx.ExecContext(ctx, s.rebind(`UPDATE history_users SET name = ?, data = ? WHERE id = ?`), u.Name, string(data), u.Id)
+	} else {
+		_, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO history_users (id, name, data) VALUES (?, ?, ?)`), u.Id, u.Name, string(data))
+	}
+	return string(data), err
+}
+
+// Versions implements onlyoffice.HistoryStore
+func (s *Store) Versions(ctx context.Context, docID string) ([]onlyoffice.HistoryVersion, error) {
+	versions, err := s.query(ctx, `WHERE v.doc_id = ? ORDER BY v.version`, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]onlyoffice.HistoryVersion, 0, len(versions))
+	for _, v := range versions {
+		out = append(out, v.HistoryVersion)
+	}
+	return out, nil
+}
+
+// Version implements onlyoffice.HistoryStore
+func (s *Store) Version(ctx context.Context, docID string, version int) (*onlyoffice.HistoryVersion, error) {
+	versions, err := s.query(ctx, `WHERE v.doc_id = ? AND v.version = ?`, docID, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("historysql: version %d of %s: %w", version, docID, storage.ErrNotExist)
+	}
+	return &versions[0].HistoryVersion, nil
+}
+
+// VersionsByUser returns the versions authored or edited by a user within
+// [since, until), across all documents, oldest first
+func (s *Store) VersionsByUser(ctx context.Context, userID string, since, until time.Time) ([]DocumentVersion, error) {
+	return s.query(ctx, `WHERE (v.user_id = ? AND v.created_at >= ? AND v.created_at < ?)
+		OR EXISTS (SELECT 1 FROM history_changes c
+			WHERE c.doc_id = v.doc_id AND c.version = v.version
+			AND c.user_id = ? AND c.created_at >= ? AND c.created_at < ?)
+		ORDER BY v.created_at, v.doc_id, v.version`,
+		userID, since.Unix(), until.Unix(), userID, since.Unix(), until.Unix())
+}
+
+// Documents returns a summary of every document with history
+func (s *Store) Documents(ctx context.Context) ([]Document, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT doc_id, COUNT(*), MAX(version), MAX(created_at) FROM history_versions GROUP BY doc_id ORDER BY doc_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		var d Document
+		var updated int64
+		if err := rows.Scan(&d.DocID, &d.Versions, &d.LatestVersion, &updated); err != nil {
+			return nil, err
+		}
+		d.Updated = time.Unix(updated, 0).UTC()
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// query loads the versions matching a condition on history_versions v
+func (s *Store) query(ctx context.Context, where string, args ...any) ([]DocumentVersion, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT v.doc_id, v.version, v.doc_key, v.created_at, v.server_version, v.file_type, v.restored_from, v.user_data
+		FROM history_versions v `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []DocumentVersion
+	for rows.Next() {
+		var v DocumentVersion
+		var created int64
+		var user sql.NullString
+		if err := rows.Scan(&v.DocID, &v.Version, &v.Key, &created, &v.ServerVersion, &v.FileType, &v.RestoredFrom, &user); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		v.Created = time.Unix(created, 0).UTC()
+		if user.Valid {
+			v.User = &models.User{}
+			if err := json.Unmarshal([]byte(user.String), v.User); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("historysql: decode user: %w", err)
+			}
+		}
+		versions = append(versions, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range versions {
+		if err := s.loadDetails(ctx, &versions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// loadDetails loads the change entries and artifacts of a version
+func (s *Store) loadDetails(ctx context.Context, v *DocumentVersion) error {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT c.created_at, c.user_id, c.user_data
+		FROM history_changes c
+		WHERE c.doc_id = ? AND c.version = ? ORDER BY c.seq`), v.DocID, v.Version)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var change models.Change
+		var created int64
+		var user sql.NullString
+		if err := rows.Scan(&created, &change.User.Id, &user); err != nil {
+			rows.Close()
+			return err
+		}
+		if created != 0 {
+			change.Created = time.Unix(created, 0).UTC().Format(changeTimeFormat)
+		}
+		if user.Valid {
+			if err := json.Unmarshal([]byte(user.String), &change.User); err != nil {
+				rows.Close()
+				return fmt.Errorf("historysql: decode user: %w", err)
+			}
+		}
+		v.ChangesData = append(v.ChangesData, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	artifacts, err := s.artifacts(ctx, v.DocID, v.Version)
+	if err != nil {
+		return err
+	}
+	for _, a := range []onlyoffice.HistoryArtifact{onlyoffice.ArtifactDocument, onlyoffice.ArtifactChanges} {
+		if _, ok := artifacts[a]; ok {
+			v.Artifacts = append(v.Artifacts, a)
+		}
+	}
+	return nil
+}
+
+// artifactRef references an artifact file in the blob storage
+type artifactRef struct {
+	name   string
+	size   int64
+	sha256 string
+}
+
+// artifacts returns the artifact references of a version
+func (s *Store) artifacts(ctx context.Context, docID string, version int) (map[onlyoffice.HistoryArtifact]artifactRef, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT artifact, object_name, size, sha256 FROM history_artifacts WHERE doc_id = ? AND version = ?`), docID, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make(map[onlyoffice.HistoryArtifact]artifactRef)
+	for rows.Next() {
+		var artifact string
+		var ref artifactRef
+		if err := rows.Scan(&artifact, &ref.name, &ref.size, &ref.sha256); err != nil {
+			return nil, err
+		}
+		refs[onlyoffice.HistoryArtifact(artifact)] = ref
+	}
+	return refs, rows.Err()
+}
+
+// OpenArtifact implements onlyoffice.HistoryStore
+func (s *Store) OpenArtifact(ctx context.Context, docID string, version int, artifact onlyoffice.HistoryArtifact) (io.ReadCloser, *storage.ObjectInfo, error) {
+	refs, err := s.artifacts(ctx, docID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, ok := refs[artifact]
+	if !ok || s.blobs == nil {
+		return nil, nil, fmt.Errorf("historysql: %s of version %d of %s: %w", artifact, version, docID, storage.ErrNotExist)
+	}
+	return s.blobs.Get(ctx, ref.name)
+}
+
+// DeleteVersion implements onlyoffice.HistoryStore
+func (s *Store) DeleteVersion(ctx context.Context, docID string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.artifacts(ctx, docID, version)
+	if err != nil {
+		return err
+	}
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM history_versions WHERE doc_id = ? AND version = ?`), docID, version)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("historysql: version %d of %s: %w", version, docID, storage.ErrNotExist)
+		}
+		for _, table := range []string{"history_changes", "history_artifacts"} {
+			if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM `+table+` WHERE doc_id = ? AND version = ?`), docID, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if err := s.blobs.Delete(ctx, ref.name); err != nil && !storage.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteArtifact implements onlyoffice.HistoryStore
+func (s *Store) DeleteArtifact(ctx context.Context, docID string, version int, artifact onlyoffice.HistoryArtifact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.artifacts(ctx, docID, version)
+	if err != nil {
+		return err
+	}
+	ref, ok := refs[artifact]
+	if !ok {
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx, s.rebind(`DELETE FROM history_artifacts WHERE doc_id = ? AND version = ? AND artifact = ?`), docID, version, string(artifact))
+	if err != nil {
+		return err
+	}
+	if err := s.blobs.Delete(ctx, ref.name); err != nil && !storage.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// objectName returns a new blob name for an artifact. Every write gets its
+// own random directory, so that writers racing for the same version number
+// never overwrite or delete each other's files; the name is recorded in
+// history_artifacts.
+func (s *Store) objectName(docID string, v *onlyoffice.HistoryVersion, artifact onlyoffice.HistoryArtifact) (string, error) {
+	name := "diff.zip"
+	if artifact == onlyoffice.ArtifactDocument {
+		name = "prev"
+		if v.FileType != "" {
+			name += "." + v.FileType
+		}
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return path.Join(s.prefix, url.PathEscape(docID), strconv.Itoa(v.Version), hex.EncodeToString(id[:]), name), nil
+}
+
+// tx runs fn in a transaction
+func (s *Store) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind rewrites ? placeholders for the dialect
+func (s *Store) rebind(query string) string {
+	if s.dialect != Dollar {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,301 @@
+package historysql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/historysql"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func newStore(t *testing.T) (*historysql.Store, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := historysql.New(db, historysql.Options{Blobs: storage.NewMemory()})
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	return store, db
+}
+
+func TestMigrate(t *testing.T) {
+	store, db := newStore(t)
+
+	// Migrations are applied once
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Second Migrate failed: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM history_migrations`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 applied migrations, got %d", n)
+	}
+}
+
+func TestStoreUserSnapshots(t *testing.T) {
+	store, _ := newStore(t)
+	ctx := context.Background()
+
+	// Renaming a user does not rewrite earlier versions, and users without
+	// an id keep their name
+	for _, user := range []models.User{{Id: "u1", Name: "Alice"}, {Id: "u1", Name: "Alice Smith"}, {Name: "Guest"}} {
+		user := user
+		_, err := store.AddVersion(ctx, "a.docx", &onlyoffice.HistoryVersion{
+			Key:         "k",
+			Created:     time.Now(),
+			User:        &user,
+			ChangesData: []models.Change{{Created: "2026-01-21 15:50:00", User: user}},
+		}, onlyoffice.HistoryArtifacts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := store.Versions(ctx, "a.docx")
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d (%v)", len(versions), err)
+	}
+	for i, want := range []string{"Alice", "Alice Smith", "Guest"} {
+		v := versions[i]
+		if v.User == nil || v.User.Name != want || v.ChangesData[0].User.Name != want {
+			t.Errorf("Version %d: expected user %q, got %+v %+v", v.Version, want, v.User, v.ChangesData)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store, _ := newStore(t)
+	ctx := context.Background()
+
+	alice := models.User{Id: "u1", Name: "Alice"}
+	bob := models.User{Id: "u2", Name: "Bob"}
+	created := time.Date(2026, 1, 21, 15, 52, 27, 0, time.UTC)
+
+	v, err := store.AddVersion(ctx, "a.docx", &onlyoffice.HistoryVersion{
+		Key:           "k1",
+		Created:       created,
+		User:          &alice,
+		ServerVersion: "7.3.0",
+		FileType:      "docx",
+		ChangesData: []models.Change{
+			{Created: "2026-01-21 15:50:00", User: alice},
+			{Created: "2026-01-21 15:52:27", User: bob},
+		},
+	}, onlyoffice.HistoryArtifacts{
+		Document: strings.NewReader("first"),
+		Changes:  strings.NewReader("changes"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 1 || len(v.Artifacts) != 2 {
+		t.Fatalf("Unexpected version %+v", v)
+	}
+	if _, err := store.AddVersion(ctx, "a.docx", &onlyoffice.HistoryVersion{Key: "k2", Created: created.Add(time.Hour)}, onlyoffice.HistoryArtifacts{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddVersion(ctx, "b.docx", &onlyoffice.HistoryVersion{Key: "kb", Created: created, User: &bob}, onlyoffice.HistoryArtifacts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := store.Versions(ctx, "a.docx")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d (%v)", len(versions), err)
+	}
+	got := versions[0]
+	if got.Key != "k1" || !got.Created.Equal(created) || got.User == nil || got.User.Name != "Alice" || got.ServerVersion != "7.3.0" || got.FileType != "docx" {
+		t.Errorf("Unexpected version %+v", got)
+	}
+	if len(got.ChangesData) != 2 || got.ChangesData[1].User.Name != "Bob" || got.ChangesData[1].Created != "2026-01-21 15:52:27" {
+		t.Errorf("Unexpected changes %+v", got.ChangesData)
+	}
+	if versions[1].Version != 2 || len(versions[1].Artifacts) != 0 {
+		t.Errorf("Unexpected version %+v", versions[1])
+	}
+
+	r, _, err := store.OpenArtifact(ctx, "a.docx", 1, onlyoffice.ArtifactChanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "changes" {
+		t.Errorf("Changes = %q", data)
+	}
+
+	// Versions edited by Bob, across documents
+	edited, err := store.VersionsByUser(ctx, "u2", created.Add(-time.Hour), created.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edited) != 2 || edited[0].DocID != "a.docx" || edited[1].DocID != "b.docx" {
+		t.Errorf("Unexpected versions by user %+v", edited)
+	}
+
+	docs, err := store.Documents(ctx)
+	if err != nil || len(docs) != 2 || docs[0].Versions != 2 || docs[0].LatestVersion != 2 {
+		t.Errorf("Unexpected documents %+v (%v)", docs, err)
+	}
+
+	if err := store.DeleteArtifact(ctx, "a.docx", 1, onlyoffice.ArtifactChanges); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.OpenArtifact(ctx, "a.docx", 1, onlyoffice.ArtifactChanges); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+	if err := store.DeleteVersion(ctx, "a.docx", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Version(ctx, "a.docx", 1); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+	if err := store.DeleteVersion(ctx, "a.docx", 1); !storage.IsNotExist(err) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
+
+func TestClientWithStore(t *testing.T) {
+	store, _ := newStore(t)
+	docs := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: docs, History: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := docs.Put(context.Background(), "a.docx", strings.NewReader("v1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if _, err := client.CreateHistory("a.docx", models.Callback{Key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := client.Prune("a.docx", onlyoffice.RetentionPolicy{KeepLast: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 2 {
+		t.Errorf("Expected 2 removed versions, got %v", res.Removed)
+	}
+	if _, err := client.RestoreVersion("a.docx", 3, onlyoffice.RestoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := client.CountVersion("a.docx"); err != nil || n != 2 {
+		t.Errorf("Expected 2 versions, got %d, %v", n, err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	store, _ := newStore(t)
+	ctx := context.Background()
+
+	// Existing history kept as JSON files in a storage
+	docs := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: docs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs.Put(ctx, "a.docx", strings.NewReader("v1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"k1", "k2"} {
+		if _, err := client.CreateHistory("a.docx", models.Callback{Key: key, History: models.History{ServerVersion: "7.3.0"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := onlyoffice.NewStorageHistory(docs)
+	if err := src.DeleteVersion(ctx, "a.docx", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.Import(ctx, src, "a.docx")
+	if err != nil || n != 1 {
+		t.Fatalf("Import = %d, %v", n, err)
+	}
+	if n, err := store.Import(ctx, src, "a.docx"); err != nil || n != 0 {
+		t.Errorf("Second import = %d, %v", n, err)
+	}
+
+	// Version numbers are kept
+	v, err := store.Version(ctx, "a.docx", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Key != "k2" || v.ServerVersion != "7.3.0" || len(v.Artifacts) != 1 {
+		t.Errorf("Unexpected imported version %+v", v)
+	}
+
+	// models.History converts through NewHistoryVersion
+	h := models.History{Key: "k5", Created: "2026-01-21 15:52:27", ServerVersion: "7.4.0"}
+	imported := *onlyoffice.NewHistoryVersion(models.Callback{History: h})
+	imported.Version = 5
+	if err := store.ImportVersion(ctx, "a.docx", imported, onlyoffice.HistoryArtifacts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ImportVersion(ctx, "a.docx", imported, onlyoffice.HistoryArtifacts{}); err == nil {
+		t.Error("Expected error importing an existing version")
+	}
+	next, err := store.AddVersion(ctx, "a.docx", &onlyoffice.HistoryVersion{Key: "k6"}, onlyoffice.HistoryArtifacts{})
+	if err != nil || next.Version != 6 {
+		t.Errorf("Expected version 6, got %+v (%v)", next, err)
+	}
+}
+
+func TestConflictingWriteKeepsArtifacts(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	blobs := storage.NewMemory()
+	ctx := context.Background()
+
+	// Two stores stand in for two processes sharing the database
+	a := historysql.New(db, historysql.Options{Blobs: blobs})
+	b := historysql.New(db, historysql.Options{Blobs: blobs})
+	if err := a.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ImportVersion(ctx, "a.docx", onlyoffice.HistoryVersion{Version: 1, FileType: "docx"}, onlyoffice.HistoryArtifacts{Document: strings.NewReader("first")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ImportVersion(ctx, "a.docx", onlyoffice.HistoryVersion{Version: 1, FileType: "docx"}, onlyoffice.HistoryArtifacts{Document: strings.NewReader("second")}); err == nil {
+		t.Fatal("Expected conflicting version to fail")
+	}
+
+	objects, err := blobs.List(ctx, ".history/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("Expected the losing write to be cleaned up, got %d objects", len(objects))
+	}
+	r, _, err := a.OpenArtifact(ctx, "a.docx", 1, onlyoffice.ArtifactDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "first" {
+		t.Errorf("Document = %q", data)
+	}
+}
@@ -0,0 +1,109 @@
+package historysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migrations holds the schema changes in order. Released migrations must
+// never be edited; append new ones instead.
+var migrations = []string{
+	// 1: versions, change entries, users and artifact references
+	`CREATE TABLE history_users (
+		id   VARCHAR(255) NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE TABLE history_versions (
+		doc_id         VARCHAR(255) NOT NULL,
+		version        INTEGER NOT NULL,
+		doc_key        VARCHAR(255) NOT NULL,
+		created_at     BIGINT NOT NULL,
+		user_id        VARCHAR(255),
+		server_version VARCHAR(64) NOT NULL,
+		file_type      VARCHAR(32) NOT NULL,
+		restored_from  INTEGER NOT NULL,
+		PRIMARY KEY (doc_id, version)
+	);
+	CREATE TABLE history_changes (
+		doc_id     VARCHAR(255) NOT NULL,
+		version    INTEGER NOT NULL,
+		seq        INTEGER NOT NULL,
+		created_at BIGINT NOT NULL,
+		user_id    VARCHAR(255) NOT NULL,
+		PRIMARY KEY (doc_id, version, seq)
+	);
+	CREATE TABLE history_artifacts (
+		doc_id      VARCHAR(255) NOT NULL,
+		version     INTEGER NOT NULL,
+		artifact    VARCHAR(32) NOT NULL,
+		object_name VARCHAR(1024) NOT NULL,
+		size        BIGINT NOT NULL,
+		sha256      VARCHAR(64) NOT NULL,
+		PRIMARY KEY (doc_id, version, artifact)
+	)`,
+
+	// 2: lookups by user and time
+	`CREATE INDEX history_versions_user ON history_versions (user_id, created_at);
+	CREATE INDEX history_changes_user ON history_changes (user_id, created_at)`,
+
+	// 3: the user as recorded with each version and change entry;
+	// history_users keeps the latest profile of each user
+	`ALTER TABLE history_versions ADD COLUMN user_data TEXT;
+	ALTER TABLE history_changes ADD COLUMN user_data TEXT;
+	UPDATE history_versions SET user_data = (SELECT u.data FROM history_users u WHERE u.id = history_versions.user_id);
+	UPDATE history_changes SET user_data = (SELECT u.data FROM history_users u WHERE u.id = history_changes.user_id)`,
+}
+
+// Migrate brings the schema up to date. Applied migrations are recorded in
+// the history_migrations table, so Migrate can run on every start.
+func (s *Store) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS history_migrations (
+		version    INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("historysql: create migrations table: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM history_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("historysql: read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if err := s.migrate(ctx, i+1, migrations[i]); err != nil {
+			return fmt.Errorf("historysql: migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// migrate applies a single migration in a transaction
+func (s *Store) migrate(ctx context.Context, version int, script string) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		for _, stmt := range splitStatements(script) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO history_migrations (version, applied_at) VALUES (?, ?)`),
+			version, time.Now().Unix())
+		return err
+	})
+}
+
+// splitStatements splits a migration script into single statements, as not
+// every driver executes several at once
+func splitStatements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
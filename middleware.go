@@ -0,0 +1,70 @@
+package onlyoffice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// CallbackMiddleware wraps the dispatch of a callback event. It may inspect
+// or modify the event and context, short-circuit with an error, or observe
+// the result of next.
+type CallbackMiddleware func(next CallbackEventFunc) CallbackEventFunc
+
+// WithMiddleware wraps the dispatch step of the handler. The first
+// middleware is the outermost one.
+func WithMiddleware(mw ...CallbackMiddleware) CallbackOption {
+	return func(h *callbackHandler) {
+		h.middleware = append(h.middleware, mw...)
+	}
+}
+
+// chain composes mw around next
+func chain(next CallbackEventFunc, mw []CallbackMiddleware) CallbackEventFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		next = mw[i](next)
+	}
+	return next
+}
+
+// RecoverMiddleware turns a panic in a handler into an error, so that the
+// Document Server receives an error response and retries
+func RecoverMiddleware() CallbackMiddleware {
+	return func(next CallbackEventFunc) CallbackEventFunc {
+		return func(ctx context.Context, ev *CallbackEvent) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("callback handler panic: %v\n%s", r, debug.Stack())
+				}
+			}()
+			return next(ctx, ev)
+		}
+	}
+}
+
+// LoggingMiddleware logs every dispatched callback with its duration and
+// result. A nil logger uses the standard logger.
+func LoggingMiddleware(logger *log.Logger) CallbackMiddleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(next CallbackEventFunc) CallbackEventFunc {
+		return func(ctx context.Context, ev *CallbackEvent) error {
+			start := time.Now()
+			err := next(ctx, ev)
+
+			cb := ev.Callback
+			if err != nil {
+				logger.Printf("onlyoffice: callback key=%s status=%s from=%s failed after %s: %v",
+					cb.Key, cb.Status, ev.RemoteAddr(), time.Since(start), err)
+			} else {
+				logger.Printf("onlyoffice: callback key=%s status=%s from=%s handled in %s",
+					cb.Key, cb.Status, ev.RemoteAddr(), time.Since(start))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package onlyoffice_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+func TestCallbackMiddleware(t *testing.T) {
+	client := newTestClient(t)
+
+	var order []string
+	trace := func(name string) onlyoffice.CallbackMiddleware {
+		return func(next onlyoffice.CallbackEventFunc) onlyoffice.CallbackEventFunc {
+			return func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+				order = append(order, name+":before")
+				err := next(ctx, ev)
+				order = append(order, name+":after")
+				return err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnEditing: func(cb *models.Callback) error {
+			order = append(order, "handler")
+			return nil
+		},
+		OnSave: func(cb *models.Callback) error {
+			panic("boom")
+		},
+	}, onlyoffice.WithMiddleware(
+		onlyoffice.LoggingMiddleware(log.New(&buf, "", 0)),
+		onlyoffice.RecoverMiddleware(),
+		trace("outer"),
+		trace("inner"),
+	))
+
+	if rec := postCallback(h, `{"status": 1, "key": "k1"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	want := "outer:before inner:before handler inner:after outer:after"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("Middleware order = %q, want %q", got, want)
+	}
+
+	if rec := postCallback(h, `{"status": 2, "key": "k2"}`); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a recovered panic to produce 500, got %d", rec.Code)
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, "key=k1 status=editing") || !strings.Contains(logs, "key=k2 status=must-save") ||
+		!strings.Contains(logs, "panic: boom") {
+		t.Errorf("Unexpected log output:\n%s", logs)
+	}
+}
@@ -0,0 +1,111 @@
+package onlyoffice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// legacyHistoryFile is the metadata file of the layout written before
+// history was kept per document
+const legacyHistoryFile = "changes.json"
+
+// MigrateLegacyHistory moves the history written by earlier releases of this
+// package into the history of docID. Those releases kept a single history
+// per storage directory, as .history/<key>/changes.json without document
+// files, and the current layout ignores it. The entries found in legacy are
+// recorded as versions of docID, oldest first, and removed from legacy once
+// all of them are stored. docID must have no history yet; a failed
+// migration removes the versions it recorded so it can be retried.
+func (c *Client) MigrateLegacyHistory(legacy storage.Storage, docID string) ([]HistoryVersion, error) {
+	ctx := context.Background()
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := readLegacyHistory(ctx, legacy)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	existing, err := store.Versions(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("migrate: history of %s is not empty", docID)
+	}
+
+	var recorded []HistoryVersion
+	for _, e := range entries {
+		v := e.version
+		stored, err := store.AddVersion(ctx, docID, &v, HistoryArtifacts{})
+		if err != nil {
+			for i := len(recorded) - 1; i >= 0; i-- {
+				store.DeleteVersion(ctx, docID, recorded[i].Version)
+			}
+			return nil, fmt.Errorf("migrate: %s: %w", e.name, err)
+		}
+		recorded = append(recorded, *stored)
+	}
+
+	for _, e := range entries {
+		if err := legacy.Delete(ctx, e.name); err != nil && !storage.IsNotExist(err) {
+			return recorded, fmt.Errorf("migrate: remove %s: %w", e.name, err)
+		}
+	}
+	return recorded, nil
+}
+
+// legacyEntry is a history entry of the legacy layout
+type legacyEntry struct {
+	name    string
+	version HistoryVersion
+}
+
+// readLegacyHistory reads the legacy history entries in st, oldest first
+func readLegacyHistory(ctx context.Context, st storage.Storage) ([]legacyEntry, error) {
+	objects, err := st.List(ctx, historyPrefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []legacyEntry
+	for _, obj := range objects {
+		key, file, ok := strings.Cut(strings.TrimPrefix(obj.Name, historyPrefix+"/"), "/")
+		if !ok || file != legacyHistoryFile {
+			continue
+		}
+
+		r, _, err := st.Get(ctx, obj.Name)
+		if err != nil {
+			return nil, err
+		}
+		var history models.History
+		err = json.NewDecoder(r).Decode(&history)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w: %v", obj.Name, ErrCorruptHistory, err)
+		}
+
+		v := NewHistoryVersion(models.Callback{Key: history.Key, History: history})
+		if v.Key == "" {
+			v.Key = key
+		}
+		entries = append(entries, legacyEntry{name: obj.Name, version: *v})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].version.Created.Before(entries[j].version.Created)
+	})
+	return entries, nil
+}
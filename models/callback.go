@@ -1,22 +1,108 @@
 package models
 
+import "strconv"
+
+// CallbackStatus is the document status reported in a callback
+type CallbackStatus int
+
+const (
+	StatusEditing   CallbackStatus = 1 // document is being edited
+	StatusMustSave  CallbackStatus = 2 // document is ready for saving
+	StatusSaveError CallbackStatus = 3 // document saving error has occurred
+	StatusClosed    CallbackStatus = 4 // document is closed with no changes
+	StatusForceSave CallbackStatus = 6 // document is being forcibly saved
+	StatusCorrupted CallbackStatus = 7 // error has occurred while force saving
+)
+
+func (s CallbackStatus) String() string {
+	switch s {
+	case StatusEditing:
+		return "editing"
+	case StatusMustSave:
+		return "must-save"
+	case StatusSaveError:
+		return "save-error"
+	case StatusClosed:
+		return "closed"
+	case StatusForceSave:
+		return "force-save"
+	case StatusCorrupted:
+		return "corrupted"
+	default:
+		return "status(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// ActionType is the kind of user action reported in a callback
+type ActionType int
+
+const (
+	ActionDisconnect ActionType = 0 // user disconnected from co-editing
+	ActionConnect    ActionType = 1 // new user connected to co-editing
+	ActionForceSave  ActionType = 2 // user clicked the forcesave button
+)
+
+func (t ActionType) String() string {
+	switch t {
+	case ActionDisconnect:
+		return "disconnect"
+	case ActionConnect:
+		return "connect"
+	case ActionForceSave:
+		return "force-save"
+	default:
+		return "action(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// ForceSaveType is the initiator of a forcesave (status 6) callback
+type ForceSaveType int
+
+const (
+	ForceSaveCommand ForceSaveType = 0 // request to the CommandService
+	ForceSaveButton  ForceSaveType = 1 // the Save button was clicked
+	ForceSaveTimer   ForceSaveType = 2 // the forcesave timer fired
+	ForceSaveForm    ForceSaveType = 3 // a form was submitted
+)
+
+func (t ForceSaveType) String() string {
+	switch t {
+	case ForceSaveCommand:
+		return "command"
+	case ForceSaveButton:
+		return "button"
+	case ForceSaveTimer:
+		return "timer"
+	case ForceSaveForm:
+		return "form"
+	default:
+		return "forcesave(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
 type Callback struct {
-	Actions     []Action `json:"actions"`
-	ChangesUrl  string   `json:"changesurl"`
-	History     History  `json:"history"`
-	Key         string   `json:"key"`
-	Status      int      `json:"status"`
-	Users       []string `json:"users"`
-	Url         string   `json:"url"`
-	FileId      string   `json:"-"`
-	Token       string   `json:"token,omitempty"`
-	Filename    string   `json:"filename,omitempty"`
-	UserAddress string   `json:"userAddress,omitempty"`
+	Actions       []Action       `json:"actions"`
+	ChangesUrl    string         `json:"changesurl"`
+	FileType      string         `json:"filetype,omitempty"`
+	ForceSaveType ForceSaveType  `json:"forcesavetype,omitempty"`
+	FormsDataUrl  string         `json:"formsdataurl,omitempty"`
+	History       History        `json:"history"`
+	Key           string         `json:"key"`
+	LastSave      string         `json:"lastsave,omitempty"`
+	NotModified   bool           `json:"notmodified,omitempty"`
+	Status        CallbackStatus `json:"status"`
+	Users         []string       `json:"users"`
+	Url           string         `json:"url"`
+	UserData      string         `json:"userdata,omitempty"`
+	FileId        string         `json:"-"`
+	Token         string         `json:"token,omitempty"`
+	Filename      string         `json:"filename,omitempty"`
+	UserAddress   string         `json:"userAddress,omitempty"`
 }
 
 type Action struct {
-	Type   int    `json:"type"`
-	UserID string `json:"userid"`
+	Type   ActionType `json:"type"`
+	UserID string     `json:"userid"`
 }
 
 type History struct {
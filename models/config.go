@@ -82,6 +82,7 @@ type Template struct {
 
 type EditorParams struct {
 	Filename    string
+	Key         string // document key; generated when empty
 	Mode        string
 	Type        string
 	Language    string
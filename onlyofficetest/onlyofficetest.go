@@ -0,0 +1,266 @@
+// Package onlyofficetest provides utilities for testing OnlyOffice callback
+// integrations without a running Document Server.
+package onlyofficetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+// Recording is a captured callback request
+type Recording struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body"`
+	ReceivedAt time.Time   `json:"receivedAt"`
+	// Status is the response status of the recorded handler
+	Status int `json:"status,omitempty"`
+}
+
+// Recorder captures the raw callback requests reaching a handler,
+// including malformed, unauthorized and duplicate deliveries
+type Recorder struct {
+	// Dir receives one JSON file per callback
+	Dir string
+	// OnError is called when a recording cannot be written. The callback
+	// itself is processed regardless.
+	OnError func(err error)
+
+	mu  sync.Mutex
+	seq int
+}
+
+// Handler returns an http.Handler recording every request before passing it
+// to next, typically the handler returned by Client.CallbackHandler. Bodies
+// are recorded up to onlyoffice.DefaultMaxCallbackBodySize.
+func (r *Recorder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rec := &Recording{
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			Header:     req.Header.Clone(),
+			ReceivedAt: time.Now(),
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.Body, onlyoffice.DefaultMaxCallbackBodySize))
+		rec.Body = body
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		if err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, req)
+		rec.Status = sw.status
+
+		if err := r.record(rec); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+	})
+}
+
+// statusWriter captures the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// record writes rec to the recording directory. The file name carries the
+// key and status of the callback when the body can be decoded.
+func (r *Recorder) record(rec *Recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+
+	label := "invalid"
+	var cb struct {
+		Key    string `json:"key"`
+		Status int    `json:"status"`
+	}
+	if json.Unmarshal(rec.Body, &cb) == nil && cb.Key != "" {
+		label = fmt.Sprintf("%s-%d", safeName(cb.Key), cb.Status)
+	}
+
+	r.mu.Lock()
+	r.seq++
+	name := fmt.Sprintf("%s-%04d-%s.json", rec.ReceivedAt.UTC().Format("20060102T150405.000000000"), r.seq, label)
+	r.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(r.Dir, name), data, 0644)
+}
+
+// LoadRecordings reads the recordings in dir in the order they were captured
+func LoadRecordings(dir string) ([]*Recording, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var recs []*Recording
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		recs = append(recs, &rec)
+	}
+	return recs, nil
+}
+
+// NewRecording returns a synthetic recording delivering cb
+func NewRecording(cb *models.Callback) (*Recording, error) {
+	body, err := json.Marshal(cb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recording{
+		Method:     http.MethodPost,
+		URL:        "/callback",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       body,
+		ReceivedAt: time.Now(),
+	}, nil
+}
+
+// NewTestCallback returns a callback with realistic defaults for status:
+// save callbacks carry a file type, last save time and a history entry,
+// editing callbacks a connected user.
+func NewTestCallback(status models.CallbackStatus, key, url string) *models.Callback {
+	now := time.Now().UTC()
+	user := models.User{Id: "user1", Name: "Test User"}
+
+	cb := &models.Callback{
+		Key:    key,
+		Status: status,
+		Url:    url,
+		Users:  []string{user.Id},
+	}
+
+	switch status {
+	case models.StatusEditing:
+		cb.Actions = []models.Action{{Type: models.ActionConnect, UserID: user.Id}}
+	case models.StatusMustSave, models.StatusForceSave, models.StatusSaveError, models.StatusCorrupted:
+		cb.FileType = strings.TrimPrefix(path.Ext(url), ".")
+		cb.LastSave = now.Format("2006-01-02T15:04:05.000Z")
+		cb.History = models.History{
+			ServerVersion: "8.0.0",
+			Created:       now.Format("2006-01-02 15:04:05"),
+			Key:           key,
+			User:          &user,
+			Changes: []models.Change{
+				{Created: now.Format("2006-01-02 15:04:05"), User: user},
+			},
+		}
+		if status == models.StatusForceSave {
+			cb.ForceSaveType = models.ForceSaveButton
+		}
+	case models.StatusClosed:
+		cb.Users = nil
+	}
+
+	return cb
+}
+
+// Replayer feeds recorded or synthetic callbacks into an http.Handler
+type Replayer struct {
+	// Secret re-signs every callback with a fresh JWT. When empty the
+	// Authorization header of the recording is dropped.
+	Secret string
+}
+
+// Replay delivers rec to h and returns the response
+func (p *Replayer) Replay(h http.Handler, rec *Recording) (*httptest.ResponseRecorder, error) {
+	method := rec.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	target := rec.URL
+	if target == "" {
+		target = "/callback"
+	}
+
+	body := rec.Body
+	header := rec.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Authorization")
+
+	if p.Secret != "" {
+		var claims jwt.MapClaims
+		if err := json.Unmarshal(body, &claims); err != nil {
+			return nil, err
+		}
+		delete(claims, "token")
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(p.Secret))
+		if err != nil {
+			return nil, err
+		}
+
+		claims["token"] = token
+		if body, err = json.Marshal(claims); err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header = header
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, nil
+}
+
+// ReplayCallback delivers cb to h and returns the response
+func (p *Replayer) ReplayCallback(h http.Handler, cb *models.Callback) (*httptest.ResponseRecorder, error) {
+	rec, err := NewRecording(cb)
+	if err != nil {
+		return nil, err
+	}
+	return p.Replay(h, rec)
+}
+
+// safeName makes s usable in a file name
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,97 @@
+package onlyofficetest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/onlyofficetest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	production, err := onlyoffice.NewClient(&onlyoffice.Config{JWTSecret: "production", JWTEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &onlyofficetest.Recorder{Dir: dir, OnError: func(err error) { t.Error(err) }}
+	h := recorder.Handler(production.CallbackHandler(onlyoffice.CallbackHandlers{}))
+
+	// Capture callbacks signed with the production secret
+	signer := &onlyofficetest.Replayer{Secret: "production"}
+	for _, cb := range []*models.Callback{
+		onlyofficetest.NewTestCallback(models.StatusEditing, "k1", ""),
+		onlyofficetest.NewTestCallback(models.StatusMustSave, "k1", "https://example.com/out.docx"),
+	} {
+		rec, err := signer.ReplayCallback(h, cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+	}
+
+	// Requests the handler rejects are recorded as well
+	malformed := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader("not json"))
+	malformed.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), malformed)
+
+	recordings, err := onlyofficetest.LoadRecordings(dir)
+	if err != nil {
+		t.Fatalf("Failed to load recordings: %v", err)
+	}
+	if len(recordings) != 3 || recordings[0].Header.Get("Authorization") == "" || recordings[0].Status != http.StatusOK {
+		t.Fatalf("Unexpected recordings %+v", recordings)
+	}
+	if rejected := recordings[2]; string(rejected.Body) != "not json" || rejected.Status == http.StatusOK {
+		t.Errorf("Unexpected recording of the malformed request %+v", rejected)
+	}
+	recordings = recordings[:2]
+
+	// Replay them against a test handler with its own secret
+	test, err := onlyoffice.NewClient(&onlyoffice.Config{JWTSecret: "test", JWTEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved *models.Callback
+	var editing int
+	th := test.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnEditing: func(cb *models.Callback) error {
+			editing++
+			return nil
+		},
+		OnSave: func(cb *models.Callback) error {
+			saved = cb
+			return nil
+		},
+	})
+
+	unsigned := &onlyofficetest.Replayer{}
+	if rec, _ := unsigned.Replay(th, recordings[0]); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected recordings with a foreign signature to be rejected, got %d", rec.Code)
+	}
+
+	replayer := &onlyofficetest.Replayer{Secret: "test"}
+	for _, r := range recordings {
+		rec, err := replayer.Replay(th, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", rec.Code)
+		}
+	}
+
+	if editing != 1 || saved == nil {
+		t.Fatalf("Expected one editing and one save callback, got %d and %v", editing, saved)
+	}
+	if saved.Key != "k1" || saved.FileType != "docx" || len(saved.History.Changes) != 1 {
+		t.Errorf("Unexpected replayed callback %+v", saved)
+	}
+}
@@ -0,0 +1,137 @@
+package onlyoffice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// Quarantine keeps the artifacts of failed saves (status 3) and corrupted
+// forcesaves (status 7), so that support can recover the edits manually.
+// Set it on CallbackHandlers to enable it; it runs before OnSaveError and
+// OnCorrupt.
+type Quarantine struct {
+	// Storage receives the quarantined files. Defaults to the Storage of the
+	// client configuration.
+	Storage storage.Storage
+	// Prefix is the directory inside Storage; default "quarantine"
+	Prefix string
+	// Alert, when set, is called after a callback has been quarantined
+	Alert func(ctx context.Context, rec *QuarantineRecord)
+}
+
+// QuarantineRecord describes a quarantined callback. It is stored as
+// record.json next to the downloaded artifacts. URL, Header and Body hold
+// the original request, so the callback can be replayed exactly.
+type QuarantineRecord struct {
+	Dir        string           `json:"dir"`
+	Key        string           `json:"key"`
+	Status     string           `json:"status"`
+	CapturedAt time.Time        `json:"capturedAt"`
+	RemoteAddr string           `json:"remoteAddr,omitempty"`
+	Files      []string         `json:"files,omitempty"`
+	Errors     []string         `json:"errors,omitempty"`
+	Callback   *models.Callback `json:"callback"`
+	URL        string           `json:"url,omitempty"`
+	Header     http.Header      `json:"header,omitempty"`
+	Body       []byte           `json:"body,omitempty"`
+}
+
+// isQuarantineStatus reports whether Quarantine handles the callback status
+func isQuarantineStatus(status models.CallbackStatus) bool {
+	return status == models.StatusSaveError || status == models.StatusCorrupted
+}
+
+// capture downloads the artifacts of ev into the quarantine area. Downloads
+// are best effort: failures are listed in the record rather than returned,
+// as the Document Server does not produce the artifacts again. Only a
+// failure to write the record itself is reported.
+func (q *Quarantine) capture(ctx context.Context, c *Client, ev *CallbackEvent) (*QuarantineRecord, error) {
+	st := q.Storage
+	if st == nil {
+		st = c.config.Storage
+	}
+	if st == nil {
+		return nil, errors.New("quarantine: no storage configured")
+	}
+
+	cb := ev.Callback
+	now := time.Now().UTC()
+	prefix := q.Prefix
+	if prefix == "" {
+		prefix = "quarantine"
+	}
+
+	rec := &QuarantineRecord{
+		Dir:        path.Join(prefix, fmt.Sprintf("%s-%s", now.Format("20060102T150405.000000000Z"), safeKey(cb.Key))),
+		Key:        cb.Key,
+		Status:     cb.Status.String(),
+		CapturedAt: now,
+		RemoteAddr: ev.RemoteAddr(),
+		Callback:   cb,
+		Header:     ev.Header().Clone(),
+		Body:       ev.Body,
+	}
+	if ev.Request != nil {
+		rec.URL = ev.Request.URL.String()
+	}
+
+	fetch := func(fileURL, name string) {
+		if fileURL == "" {
+			return
+		}
+		body, err := c.openURL(ctx, fileURL)
+		if err == nil {
+			_, err = st.Put(ctx, path.Join(rec.Dir, name), body)
+			body.Close()
+		}
+		if err != nil {
+			rec.Errors = append(rec.Errors, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		rec.Files = append(rec.Files, name)
+	}
+
+	docName := "document"
+	if cb.FileType != "" {
+		docName += "." + strings.ToLower(cb.FileType)
+	}
+	fetch(cb.Url, docName)
+	fetch(cb.ChangesUrl, "changes.zip")
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := st.Put(ctx, path.Join(rec.Dir, "record.json"), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("quarantine: store record: %w", err)
+	}
+
+	if q.Alert != nil {
+		q.Alert(ctx, rec)
+	}
+	return rec, nil
+}
+
+// safeKey makes a document key usable as a path element
+func safeKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, key)
+	if key == "" || key == "." || key == ".." {
+		return "_"
+	}
+	return key
+}
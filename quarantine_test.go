@@ -0,0 +1,84 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func TestQuarantine(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/out.docx" {
+			w.Write([]byte("unsaved edits"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer files.Close()
+
+	dir := t.TempDir()
+	client := newTestClient(t)
+
+	var alerted *onlyoffice.QuarantineRecord
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		Quarantine: &onlyoffice.Quarantine{
+			Storage: storage.NewFS(dir),
+			Alert: func(ctx context.Context, rec *onlyoffice.QuarantineRecord) {
+				alerted = rec
+			},
+		},
+		OnCorruptEvent: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			if ev.Quarantined == nil {
+				t.Error("Expected the quarantine record on the event")
+			}
+			return nil
+		},
+	})
+
+	rec := postCallback(h, fmt.Sprintf(`{
+		"status": 7, "key": "k/1", "filetype": "docx", "vendor": "x",
+		"url": "%s/out.docx", "changesurl": "%s/missing.zip"
+	}`, files.URL, files.URL))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	if alerted == nil {
+		t.Fatal("Expected an alert")
+	}
+	if len(alerted.Files) != 1 || len(alerted.Errors) != 1 || alerted.Status != "corrupted" {
+		t.Errorf("Unexpected record %+v", alerted)
+	}
+
+	qdir := filepath.Join(dir, filepath.FromSlash(alerted.Dir))
+	assertFile(t, filepath.Join(qdir, "document.docx"), "unsaved edits")
+
+	data, err := os.ReadFile(filepath.Join(qdir, "record.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored onlyoffice.QuarantineRecord
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Callback == nil || stored.Callback.Key != "k/1" || stored.CapturedAt.IsZero() {
+		t.Errorf("Unexpected stored record %+v", stored)
+	}
+
+	// The original request is kept byte for byte, including unknown fields
+	var raw map[string]any
+	if err := json.Unmarshal(stored.Body, &raw); err != nil || raw["vendor"] != "x" {
+		t.Errorf("Expected the raw body in the record, got %q", stored.Body)
+	}
+	if stored.Header.Get("Content-Type") == "" || stored.URL == "" {
+		t.Errorf("Expected the request metadata in the record, got %+v", stored)
+	}
+}
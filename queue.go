@@ -0,0 +1,518 @@
+package onlyoffice
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+// QueueState is the processing state of a queued callback
+type QueueState string
+
+const (
+	QueuePending QueueState = "pending" // waiting for a worker or a retry
+	QueueDead    QueueState = "dead"    // gave up after MaxAttempts failures
+)
+
+// QueuedCallback is a callback persisted in a CallbackQueue
+type QueuedCallback struct {
+	ID          string           `json:"id"`
+	Callback    *models.Callback `json:"callback"`
+	Body        []byte           `json:"body,omitempty"`
+	Method      string           `json:"method"`
+	URL         string           `json:"url"`
+	Header      http.Header      `json:"header,omitempty"`
+	RemoteAddr  string           `json:"remoteAddr,omitempty"`
+	ReceivedAt  time.Time        `json:"receivedAt"`
+	State       QueueState       `json:"state"`
+	Attempts    int              `json:"attempts"`
+	NextAttempt time.Time        `json:"nextAttempt"`
+	LastError   string           `json:"lastError,omitempty"`
+}
+
+// QueueOptions tunes retries of a CallbackQueue
+type QueueOptions struct {
+	MaxAttempts  int           // failures before dead-lettering; default 10
+	BaseDelay    time.Duration // delay after the first failure; default 1s
+	MaxDelay     time.Duration // upper bound of the exponential delay; default 10m
+	PollInterval time.Duration // idle wake-up interval of workers; default 1s
+}
+
+// queueRecord is a line of the queue log
+type queueRecord struct {
+	Op    string          `json:"op"`
+	Entry *QueuedCallback `json:"entry,omitempty"`
+	ID    string          `json:"id,omitempty"`
+}
+
+// CallbackQueue is a durable queue of parsed callbacks. Entries are appended
+// to a log file before the Document Server gets its response, and processed
+// by workers started with Run. Attach it to a handler with WithQueue. The log
+// is rewritten with the live entries when it is opened and whenever
+// completed or superseded records make up most of it.
+//
+// Callbacks are verified when they are enqueued, so the Authorization header
+// and token are not persisted and entries are not verified again when they
+// are processed.
+//
+// Callbacks of the same document key are processed one at a time in arrival
+// order: an entry is not handed out while an earlier one with its key is
+// being processed or waiting for a retry.
+type CallbackQueue struct {
+	opts    QueueOptions
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]*QueuedCallback
+	running map[string]bool
+	notify  chan struct{}
+	seq     int64
+	process CallbackEventFunc
+	report  ErrorReporter // errors persisting outcomes, reported with status 500
+	records int           // lines in the log
+}
+
+// minQueueCompaction is the number of records a queue log may hold beyond
+// twice its live entries before it is compacted
+const minQueueCompaction = 1024
+
+// OpenCallbackQueue opens or creates the queue log at path and restores
+// pending and dead-lettered entries from it
+func OpenCallbackQueue(path string, opts QueueOptions) (*CallbackQueue, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = time.Second
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	q := &CallbackQueue{
+		opts:    opts,
+		path:    path,
+		entries: make(map[string]*QueuedCallback),
+		running: make(map[string]bool),
+		notify:  make(chan struct{}),
+	}
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load replays the queue log
+func (q *CallbackQueue) load() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec queueRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A crash may leave a truncated last line behind
+			continue
+		}
+		switch rec.Op {
+		case "put":
+			if rec.Entry != nil {
+				q.entries[rec.Entry.ID] = rec.Entry
+			}
+		case "done":
+			delete(q.entries, rec.ID)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the log with the live entries only
+func (q *CallbackQueue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, e := range q.sorted() {
+		if err := enc.Encode(queueRecord{Op: "put", Entry: e}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	q.records = len(q.entries)
+
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+// Compact rewrites the queue log, dropping completed entries
+func (q *CallbackQueue) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.compact()
+}
+
+// append writes a record to the log and syncs it to disk, compacting the
+// log first when most of it is obsolete
+func (q *CallbackQueue) append(rec queueRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if q.records > 2*len(q.entries)+minQueueCompaction {
+		if err := q.compact(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := q.file.Sync(); err != nil {
+		return err
+	}
+	q.records++
+	return nil
+}
+
+// broadcast wakes up idle workers
+func (q *CallbackQueue) broadcast() {
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// Enqueue persists ev for asynchronous processing, without its
+// Authorization header and token
+func (q *CallbackQueue) Enqueue(ev *CallbackEvent) error {
+	var callback *models.Callback
+	if ev.Callback != nil {
+		copied := *ev.Callback
+		copied.Token = ""
+		callback = &copied
+	}
+	e := &QueuedCallback{
+		Callback:   callback,
+		Body:       ev.Body,
+		ReceivedAt: ev.ReceivedAt,
+		State:      QueuePending,
+	}
+	if ev.Request != nil {
+		e.Method = ev.Request.Method
+		e.URL = ev.Request.URL.String()
+		e.Header = ev.Request.Header.Clone()
+		e.Header.Del("Authorization")
+		e.RemoteAddr = ev.Request.RemoteAddr
+	}
+	e.NextAttempt = time.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	e.ID = fmt.Sprintf("%d-%d", time.Now().UnixNano(), q.seq)
+	if err := q.append(queueRecord{Op: "put", Entry: e}); err != nil {
+		return err
+	}
+	q.entries[e.ID] = e
+	q.broadcast()
+	return nil
+}
+
+// Run processes queued callbacks with the given number of workers until ctx
+// is cancelled
+func (q *CallbackQueue) Run(ctx context.Context, workers int) error {
+	q.mu.Lock()
+	process := q.process
+	q.mu.Unlock()
+	if process == nil {
+		return errors.New("callback queue is not attached to a handler")
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, process)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// work is the loop of a single worker
+func (q *CallbackQueue) work(ctx context.Context, process CallbackEventFunc) {
+	for {
+		e, wait, notify := q.next()
+		if e == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-notify:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+
+		err := process(ctx, e.event(ctx))
+		if err != nil && ctx.Err() != nil {
+			// Shutting down: leave the entry pending without counting the attempt
+			q.release(e.ID)
+			return
+		}
+		q.finish(e.ID, err)
+	}
+}
+
+// next claims the next due entry, or reports how long to wait for one.
+// Entries behind a running or retrying entry of the same key are skipped.
+func (q *CallbackQueue) next() (*QueuedCallback, time.Duration, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	wait := q.opts.PollInterval
+	blocked := make(map[string]bool) // document keys with an earlier entry
+	for _, e := range q.sorted() {
+		if e.State != QueuePending {
+			continue
+		}
+		key := e.key()
+		if blocked[key] {
+			continue
+		}
+		if q.running[e.ID] {
+			blocked[key] = true
+			continue
+		}
+		if d := e.NextAttempt.Sub(now); d > 0 {
+			if d < wait {
+				wait = d
+			}
+			blocked[key] = true
+			continue
+		}
+		q.running[e.ID] = true
+		copied := *e
+		return &copied, 0, q.notify
+	}
+	return nil, wait, q.notify
+}
+
+// release returns a claimed entry to the queue unchanged
+func (q *CallbackQueue) release(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, id)
+	q.broadcast()
+}
+
+// finish records the outcome of processing an entry. Errors writing the
+// outcome to the log are reported through the handler's ErrorReporter.
+func (q *CallbackQueue) finish(id string, procErr error) {
+	e, err := q.record(id, procErr)
+	if err == nil {
+		return
+	}
+	q.mu.Lock()
+	report := q.report
+	q.mu.Unlock()
+	if report != nil {
+		report(e.event(context.Background()).Request, http.StatusInternalServerError,
+			fmt.Errorf("queue: persist outcome of %s: %w", id, err))
+	}
+}
+
+// record updates an entry with the outcome of processing it and appends it
+// to the log. It returns a copy of the entry and the error of the append.
+func (q *CallbackQueue) record(id string, procErr error) (*QueuedCallback, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.broadcast()
+
+	delete(q.running, id)
+	e, ok := q.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *e
+
+	if procErr == nil {
+		delete(q.entries, id)
+		return &copied, q.append(queueRecord{Op: "done", ID: id})
+	}
+
+	e.Attempts++
+	e.LastError = procErr.Error()
+	if e.Attempts >= q.opts.MaxAttempts {
+		e.State = QueueDead
+	} else {
+		e.NextAttempt = time.Now().Add(q.backoff(e.Attempts))
+	}
+	copied = *e
+	return &copied, q.append(queueRecord{Op: "put", Entry: e})
+}
+
+// backoff returns the delay before retry number attempt
+func (q *CallbackQueue) backoff(attempt int) time.Duration {
+	d := q.opts.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= q.opts.MaxDelay {
+			return q.opts.MaxDelay
+		}
+	}
+	return d
+}
+
+// Pending returns the entries waiting to be processed
+func (q *CallbackQueue) Pending() []QueuedCallback {
+	return q.list(QueuePending)
+}
+
+// DeadLetters returns the entries that exhausted their retries
+func (q *CallbackQueue) DeadLetters() []QueuedCallback {
+	return q.list(QueueDead)
+}
+
+func (q *CallbackQueue) list(state QueueState) []QueuedCallback {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var out []QueuedCallback
+	for _, e := range q.sorted() {
+		if e.State == state {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// Replay moves a dead-lettered entry back into the queue with a fresh
+// retry budget
+func (q *CallbackQueue) Replay(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.entries[id]
+	if !ok || e.State != QueueDead {
+		return fmt.Errorf("no dead-lettered callback %q", id)
+	}
+
+	replayed := *e
+	replayed.State = QueuePending
+	replayed.Attempts = 0
+	replayed.NextAttempt = time.Now()
+	if err := q.append(queueRecord{Op: "put", Entry: &replayed}); err != nil {
+		return err
+	}
+	*e = replayed
+	q.broadcast()
+	return nil
+}
+
+// Discard removes an entry from the queue
+func (q *CallbackQueue) Discard(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.entries[id]; !ok {
+		return fmt.Errorf("no queued callback %q", id)
+	}
+	if err := q.append(queueRecord{Op: "done", ID: id}); err != nil {
+		return err
+	}
+	delete(q.entries, id)
+	return nil
+}
+
+// Close closes the queue log
+func (q *CallbackQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
+
+// sorted returns the entries in arrival order
+func (q *CallbackQueue) sorted() []*QueuedCallback {
+	out := make([]*QueuedCallback, 0, len(q.entries))
+	for _, e := range q.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].ReceivedAt.Equal(out[j].ReceivedAt) {
+			return out[i].ReceivedAt.Before(out[j].ReceivedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// key returns the document key an entry is serialized on
+func (e *QueuedCallback) key() string {
+	if e.Callback == nil {
+		return ""
+	}
+	return e.Callback.Key
+}
+
+// event rebuilds the callback event of a queued entry
+func (e *QueuedCallback) event(ctx context.Context) *CallbackEvent {
+	ev := &CallbackEvent{
+		Callback:   e.Callback,
+		Body:       e.Body,
+		ReceivedAt: e.ReceivedAt,
+	}
+	if req, err := http.NewRequestWithContext(ctx, e.Method, e.URL, nil); err == nil {
+		req.Header = e.Header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.RemoteAddr = e.RemoteAddr
+		ev.Request = req
+	}
+	return ev
+}
@@ -0,0 +1,250 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+)
+
+func TestCallbackQueue(t *testing.T) {
+	client := newTestClient(t)
+	path := filepath.Join(t.TempDir(), "callbacks.log")
+	opts := onlyoffice.QueueOptions{
+		MaxAttempts:  3,
+		BaseDelay:    time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	queue, err := onlyoffice.OpenCallbackQueue(path, opts)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	processed := make(chan string, 10)
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnSaveEvent: func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+			mu.Lock()
+			attempts[ev.Callback.Key]++
+			n := attempts[ev.Callback.Key]
+			mu.Unlock()
+
+			if ev.Request.URL.Query().Get("doc") != "42" {
+				t.Error("Expected the request metadata to be restored")
+			}
+			if ev.Callback.Key == "flaky" && n < 2 || ev.Callback.Key == "broken" {
+				return errors.New("failure")
+			}
+			processed <- ev.Callback.Key
+			return nil
+		},
+	}, onlyoffice.WithQueue(queue))
+
+	// Callbacks are acknowledged before any handler runs
+	for _, body := range []string{
+		`{"status": 2, "key": "flaky", "url": "https://example.com/a.docx"}`,
+		`{"status": 2, "key": "broken", "url": "https://example.com/b.docx"}`,
+	} {
+		if rec := postCallback(h, body); rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+	}
+	if n := len(queue.Pending()); n != 2 {
+		t.Fatalf("Expected 2 pending callbacks, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx, 2)
+		close(done)
+	}()
+
+	select {
+	case key := <-processed:
+		if key != "flaky" {
+			t.Errorf("Unexpected processed key %q", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the retry")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(queue.DeadLetters()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	dead := queue.DeadLetters()
+	if len(dead) != 1 || dead[0].Callback.Key != "broken" || dead[0].Attempts != 3 {
+		t.Fatalf("Unexpected dead letters %+v", dead)
+	}
+	queue.Close()
+
+	// Dead letters survive a restart and can be replayed
+	queue, err = onlyoffice.OpenCallbackQueue(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	if len(queue.Pending()) != 0 || len(queue.DeadLetters()) != 1 {
+		t.Fatalf("Unexpected state after reopening: %d pending, %d dead", len(queue.Pending()), len(queue.DeadLetters()))
+	}
+	if err := queue.Replay(dead[0].ID); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if len(queue.Pending()) != 1 || queue.Pending()[0].Attempts != 0 {
+		t.Errorf("Expected replayed callback to be pending with a fresh budget")
+	}
+}
+
+func TestCallbackQueueSerializesKey(t *testing.T) {
+	client := newTestClient(t)
+	queue, err := onlyoffice.OpenCallbackQueue(filepath.Join(t.TempDir(), "callbacks.log"), onlyoffice.QueueOptions{
+		BaseDelay:    50 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+
+	var mu sync.Mutex
+	inflight := map[string]int{}
+	var order []string
+	failed := false
+	process := func(ctx context.Context, ev *onlyoffice.CallbackEvent) error {
+		key := ev.Callback.Key
+		mu.Lock()
+		inflight[key]++
+		if inflight[key] > 1 {
+			t.Errorf("Callbacks of %s processed concurrently", key)
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inflight[key]--
+		// The force save fails once and is retried after a backoff
+		if ev.Callback.Status == 6 && !failed {
+			failed = true
+			return errors.New("failure")
+		}
+		order = append(order, fmt.Sprintf("%s:%d", key, ev.Callback.Status))
+		return nil
+	}
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{
+		OnSaveEvent:      process,
+		OnForceSaveEvent: process,
+	}, onlyoffice.WithQueue(queue))
+
+	postCallback(h, `{"status": 6, "key": "k", "url": "https://example.com/a.docx"}`)
+	postCallback(h, `{"status": 2, "key": "k", "url": "https://example.com/a.docx"}`)
+	postCallback(h, `{"status": 2, "key": "other", "url": "https://example.com/b.docx"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx, 3)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(queue.Pending()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	got := strings.Join(order, " ")
+	if got != "other:2 k:6 k:2" {
+		t.Errorf("Processing order = %q, want the retried force save before the save of k", got)
+	}
+}
+
+func TestCallbackQueueReportsPersistErrors(t *testing.T) {
+	client := newTestClient(t)
+	queue, err := onlyoffice.OpenCallbackQueue(filepath.Join(t.TempDir(), "callbacks.log"), onlyoffice.QueueOptions{
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reported := make(chan error, 1)
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{},
+		onlyoffice.WithQueue(queue),
+		onlyoffice.WithErrorReporter(func(r *http.Request, status int, err error) {
+			reported <- err
+		}))
+	postCallback(h, `{"status": 4, "key": "k"}`)
+
+	// The outcome cannot be written once the log is closed
+	queue.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx, 1)
+
+	select {
+	case err := <-reported:
+		if !strings.Contains(err.Error(), "persist") {
+			t.Errorf("Unexpected reported error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the persist error to be reported")
+	}
+}
+
+func TestCallbackQueueCompaction(t *testing.T) {
+	client := newTestClient(t)
+	path := filepath.Join(t.TempDir(), "callbacks.log")
+	queue, err := onlyoffice.OpenCallbackQueue(path, onlyoffice.QueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{}, onlyoffice.WithQueue(queue))
+
+	// Credentials are verified on arrival and never written to the log
+	req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(`{"status": 4, "key": "k", "token": "body-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer header-token")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	pending := queue.Pending()
+	if len(pending) != 1 || pending[0].Header.Get("Authorization") != "" || pending[0].Callback.Token != "" {
+		t.Fatalf("Expected the credentials to be dropped, got %+v", pending)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "header-token") {
+		t.Errorf("Expected no token in the log, got %s", data)
+	}
+
+	// Discarded entries are dropped from the log once they dominate it
+	for i := 0; i < 1500; i++ {
+		postCallback(h, fmt.Sprintf(`{"status": 4, "key": "k%d"}`, i))
+		if err := queue.Discard(queue.Pending()[1].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n > 2000 {
+		t.Errorf("Expected the log to be compacted, got %d records", n)
+	}
+}
@@ -0,0 +1,226 @@
+package onlyoffice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// maxRestoreBodySize limits the body of restore requests
+const maxRestoreBodySize = 64 << 10
+
+// RestoreOptions describes who restores a version and the document being
+// replaced
+type RestoreOptions struct {
+	// User is the restoring user, recorded as the author of the new version
+	User *models.User
+	// Key is the document key of the current document. It is recorded with
+	// the version that keeps the replaced file; when empty, the key of the
+	// latest version is used.
+	Key string
+}
+
+// RestoreResult describes a restored document
+type RestoreResult struct {
+	// Version is the version recorded for the restore. It keeps the file
+	// that was current before the restore.
+	Version *HistoryVersion `json:"version"`
+	// Key is the new document key. Editors must reopen the document with it
+	// to load the restored content.
+	Key string `json:"key"`
+}
+
+// RestoreVersion makes the file of a previous version the current document.
+// The replaced file is recorded as a new version authored by the restoring
+// user, so the restore itself can be undone, and a new document key is
+// issued. The document is read from and written to Config.Storage under
+// docID.
+func (c *Client) RestoreVersion(docID string, version int, opts RestoreOptions) (*RestoreResult, error) {
+	return c.restoreVersion(context.Background(), docID, version, opts)
+}
+
+// restoreVersion implements RestoreVersion
+func (c *Client) restoreVersion(ctx context.Context, docID string, version int, opts RestoreOptions) (*RestoreResult, error) {
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+	st := c.config.Storage
+	if st == nil {
+		return nil, errors.New("restore: no storage configured")
+	}
+
+	target, err := store.Version(ctx, docID, version)
+	if err != nil {
+		return nil, fmt.Errorf("restore: version %d: %w", version, err)
+	}
+	if !slices.Contains(target.Artifacts, ArtifactDocument) {
+		return nil, fmt.Errorf("restore: version %d has no document: %w", version, storage.ErrNotExist)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	v := &HistoryVersion{
+		Key:          opts.Key,
+		Created:      now,
+		User:         opts.User,
+		RestoredFrom: version,
+	}
+	if opts.User != nil {
+		v.ChangesData = []models.Change{{Created: now.Format(historyTimeFormat), User: *opts.User}}
+	}
+
+	v.FileType = strings.ToLower(strings.TrimPrefix(path.Ext(docID), "."))
+
+	// Saves and restores of the document must not interleave between
+	// replacing the file and recording the version
+	unlock := documentLocks.lock(docID)
+	defer unlock()
+
+	if v.Key == "" {
+		versions, err := store.Versions(ctx, docID)
+		if err != nil {
+			return nil, fmt.Errorf("restore: %w", err)
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("restore: no document key for %s", docID)
+		}
+		v.Key = versions[len(versions)-1].Key
+	}
+
+	// The replaced file is kept in memory, so the document can be put back
+	// if the version cannot be recorded
+	var previous []byte
+	current, _, err := st.Get(ctx, docID)
+	switch {
+	case err == nil:
+		previous, err = io.ReadAll(current)
+		current.Close()
+		if err != nil {
+			return nil, fmt.Errorf("restore: read current document: %w", err)
+		}
+	case !storage.IsNotExist(err):
+		return nil, fmt.Errorf("restore: open current document: %w", err)
+	}
+
+	file, _, err := store.OpenArtifact(ctx, docID, version, ArtifactDocument)
+	if err != nil {
+		return nil, fmt.Errorf("restore: open version %d: %w", version, err)
+	}
+	_, err = st.Put(ctx, docID, file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("restore: store document: %w", err)
+	}
+
+	var artifacts HistoryArtifacts
+	if previous != nil {
+		artifacts.Document = bytes.NewReader(previous)
+	}
+	recorded, err := store.AddVersion(ctx, docID, v, artifacts)
+	if err != nil {
+		if previous != nil {
+			st.Put(ctx, docID, bytes.NewReader(previous))
+		} else {
+			st.Delete(ctx, docID)
+		}
+		return nil, fmt.Errorf("restore: record version: %w", err)
+	}
+
+	key, err := c.GenerateFileHash(docID)
+	if err != nil {
+		return nil, err
+	}
+	return &RestoreResult{Version: recorded, Key: key}, nil
+}
+
+// RestoreRequest is the body posted to RestoreHandler. It carries the data
+// of the editor's onRequestRestore event together with the document ID.
+type RestoreRequest struct {
+	DocID   string `json:"docId"`
+	Version int    `json:"version"`
+	// Key is the document key the editor is open with
+	Key string `json:"key,omitempty"`
+}
+
+// RestoreAuthorizer checks a restore request and returns the restoring user.
+// An error rejects the request with 403.
+type RestoreAuthorizer func(r *http.Request, req *RestoreRequest) (*models.User, error)
+
+// restoreHandler serves restore requests from the editor
+type restoreHandler struct {
+	client    *Client
+	authorize RestoreAuthorizer
+}
+
+// RestoreHandler returns an http.Handler for the editor's onRequestRestore
+// event. The page posts a RestoreRequest as JSON and receives the
+// RestoreResult together with the updated history:
+//
+//	{"version": {...}, "key": "new key", "history": [...]}
+//
+// The page then reopens the editor with the new key. authorize is required,
+// as the handler rewrites documents; RestoreHandler panics if it is nil.
+func (c *Client) RestoreHandler(authorize RestoreAuthorizer) http.Handler {
+	if authorize == nil {
+		panic("onlyoffice: RestoreHandler requires an authorizer")
+	}
+	return &restoreHandler{client: c, authorize: authorize}
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h *restoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RestoreRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRestoreBodySize)).Decode(&req); err != nil {
+		http.Error(w, "invalid restore request", http.StatusBadRequest)
+		return
+	}
+	if req.DocID == "" || req.Version < 1 {
+		http.Error(w, "invalid restore request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.authorize(r, &req)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	res, err := h.client.restoreVersion(r.Context(), req.DocID, req.Version, RestoreOptions{User: user, Key: req.Key})
+	if err != nil {
+		if storage.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	store, _ := h.client.historyStore()
+	history, err := store.Versions(r.Context(), req.DocID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*RestoreResult
+		History []HistoryVersion `json:"history"`
+	}{res, history})
+}
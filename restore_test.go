@@ -0,0 +1,191 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// newRestoreClient returns a client whose document has two versions: "v1"
+// as version 1 and "v2" as version 2, with "v3" current
+func newRestoreClient(t *testing.T) (*onlyoffice.Client, storage.Storage) {
+	t.Helper()
+
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, content := range []string{"v1", "v2"} {
+		if _, err := st.Put(ctx, "a.docx", strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.CreateHistory("a.docx", models.Callback{Key: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Put(ctx, "a.docx", strings.NewReader("v3")); err != nil {
+		t.Fatal(err)
+	}
+	return client, st
+}
+
+func readObject(t *testing.T, st storage.Storage, name string) string {
+	t.Helper()
+
+	r, _, err := st.Get(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", name, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestRestoreVersion(t *testing.T) {
+	client, st := newRestoreClient(t)
+
+	user := &models.User{Id: "u1", Name: "Alice"}
+	res, err := client.RestoreVersion("a.docx", 1, onlyoffice.RestoreOptions{User: user, Key: "k3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Key == "" || res.Version.Version != 3 || res.Version.RestoredFrom != 1 || res.Version.Key != "k3" {
+		t.Fatalf("Unexpected result %+v %+v", res, res.Version)
+	}
+	if got := readObject(t, st, "a.docx"); got != "v1" {
+		t.Errorf("Current document = %q, want v1", got)
+	}
+
+	versions, err := client.GetHistory("a.docx")
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d (%v)", len(versions), err)
+	}
+	v := versions[2]
+	if v.RestoredFrom != 1 || v.User == nil || v.User.Id != "u1" || len(v.ChangesData) != 1 {
+		t.Errorf("Unexpected restore version %+v", v)
+	}
+
+	// The replaced document is kept so the restore can be undone
+	r, _, err := client.OpenHistoryArtifact("a.docx", 3, onlyoffice.ArtifactDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "v3" {
+		t.Errorf("Version 3 document = %q, want v3", data)
+	}
+
+	if _, err := client.RestoreVersion("a.docx", 9, onlyoffice.RestoreOptions{}); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+
+	// Without a key the version takes the key of the latest version
+	res, err = client.RestoreVersion("a.docx", 2, onlyoffice.RestoreOptions{User: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version.Version != 4 || res.Version.Key != "k3" {
+		t.Errorf("Expected version 4 with key k3, got %+v", res.Version)
+	}
+}
+
+func TestRestoreHandler(t *testing.T) {
+	client, st := newRestoreClient(t)
+
+	h := client.RestoreHandler(func(r *http.Request, req *onlyoffice.RestoreRequest) (*models.User, error) {
+		if r.Header.Get("X-User") == "" {
+			return nil, errors.New("anonymous")
+		}
+		return &models.User{Id: r.Header.Get("X-User")}, nil
+	})
+
+	post := func(user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/restore", strings.NewReader(body))
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("", `{"docId": "a.docx", "version": 2}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+	if rec := post("u1", `{"docId": "a.docx"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+	if rec := post("u1", `{"docId": "a.docx", "version": 7}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+
+	rec := post("u1", `{"docId": "a.docx", "version": 2, "key": "k3"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Key     string                      `json:"key"`
+		Version onlyoffice.HistoryVersion   `json:"version"`
+		History []onlyoffice.HistoryVersion `json:"history"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Key == "" || resp.Version.Version != 3 || resp.Version.User.Id != "u1" || len(resp.History) != 3 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if got := readObject(t, st, "a.docx"); got != "v2" {
+		t.Errorf("Current document = %q, want v2", got)
+	}
+}
+
+// readOnlyHistory refuses to record versions
+type readOnlyHistory struct {
+	*onlyoffice.StorageHistory
+}
+
+func (readOnlyHistory) AddVersion(ctx context.Context, docID string, v *onlyoffice.HistoryVersion, artifacts onlyoffice.HistoryArtifacts) (*onlyoffice.HistoryVersion, error) {
+	return nil, errors.New("read-only")
+}
+
+func TestRestoreVersionRecordFails(t *testing.T) {
+	_, st := newRestoreClient(t)
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{
+		Storage: st,
+		History: readOnlyHistory{onlyoffice.NewStorageHistory(st)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.RestoreVersion("a.docx", 1, onlyoffice.RestoreOptions{}); err == nil {
+		t.Fatal("Expected restore to fail")
+	}
+	if got := readObject(t, st, "a.docx"); got != "v3" {
+		t.Errorf("Current document = %q, want v3 after a failed restore", got)
+	}
+}
+
+func TestRestoreHandlerRequiresAuthorizer(t *testing.T) {
+	client, _ := newRestoreClient(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected RestoreHandler to panic without an authorizer")
+		}
+	}()
+	client.RestoreHandler(nil)
+}
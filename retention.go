@@ -0,0 +1,147 @@
+package onlyoffice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy selects the history versions to keep. A version is kept
+// when any rule matches it; the newest version is always kept so that
+// version numbers keep increasing. A zero policy keeps every version.
+type RetentionPolicy struct {
+	// KeepLast keeps the n newest versions
+	KeepLast int
+	// KeepWithin keeps versions created within the duration
+	KeepWithin time.Duration
+	// KeepDaily keeps the newest version of each of the last n days that
+	// have versions
+	KeepDaily int
+	// KeepWeekly keeps the newest version of each of the last n ISO weeks
+	// that have versions
+	KeepWeekly int
+	// Pinned lists versions that are never removed
+	Pinned []int
+}
+
+// PruneResult describes the outcome of Prune
+type PruneResult struct {
+	DocID  string `json:"docId"`
+	DryRun bool   `json:"dryRun"`
+	// Kept and Removed list version numbers, oldest first
+	Kept    []int `json:"kept"`
+	Removed []int `json:"removed"`
+	// Invalidated lists kept versions whose changes.zip was removed because
+	// the version it led to no longer exists
+	Invalidated []int `json:"invalidated,omitempty"`
+}
+
+// Prune removes the versions of a document that the policy does not keep.
+// Version numbers are never reassigned, so the remaining history stays valid
+// for the editor. The changes.zip of a kept version describes the edits
+// leading to the next version; it is removed when that version is pruned,
+// as the editor could no longer apply it. With dryRun set, nothing is
+// deleted and the result reports what would be removed.
+func (c *Client) Prune(docID string, policy RetentionPolicy, dryRun bool) (*PruneResult, error) {
+	store, err := c.historyStore()
+	if err != nil {
+		return nil, err
+	}
+	return pruneHistory(context.Background(), store, docID, policy, dryRun, time.Now())
+}
+
+// pruneHistory implements Prune
+func pruneHistory(ctx context.Context, store HistoryStore, docID string, policy RetentionPolicy, dryRun bool, now time.Time) (*PruneResult, error) {
+	versions, err := store.Versions(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &PruneResult{DocID: docID, DryRun: dryRun, Kept: []int{}, Removed: []int{}}
+	keep := policy.keep(versions, now)
+	for i, v := range versions {
+		if keep[v.Version] {
+			res.Kept = append(res.Kept, v.Version)
+			// The diff of a kept version is orphaned when its successor goes
+			next := i + 1
+			if next < len(versions) && !keep[versions[next].Version] && slices.Contains(v.Artifacts, ArtifactChanges) {
+				res.Invalidated = append(res.Invalidated, v.Version)
+			}
+		} else {
+			res.Removed = append(res.Removed, v.Version)
+		}
+	}
+
+	if dryRun {
+		return res, nil
+	}
+
+	for _, n := range res.Removed {
+		if err := store.DeleteVersion(ctx, docID, n); err != nil {
+			return res, fmt.Errorf("prune: delete version %d: %w", n, err)
+		}
+	}
+	for _, n := range res.Invalidated {
+		if err := store.DeleteArtifact(ctx, docID, n, ArtifactChanges); err != nil {
+			return res, fmt.Errorf("prune: delete changes of version %d: %w", n, err)
+		}
+	}
+	return res, nil
+}
+
+// keep returns the version numbers the policy keeps
+func (p RetentionPolicy) keep(versions []HistoryVersion, now time.Time) map[int]bool {
+	keep := make(map[int]bool, len(versions))
+	if len(versions) == 0 {
+		return keep
+	}
+
+	newest := slices.Clone(versions)
+	sort.SliceStable(newest, func(i, j int) bool { return newest[i].Version > newest[j].Version })
+
+	if p.KeepLast == 0 && p.KeepWithin == 0 && p.KeepDaily == 0 && p.KeepWeekly == 0 && len(p.Pinned) == 0 {
+		for _, v := range newest {
+			keep[v.Version] = true
+		}
+		return keep
+	}
+
+	keep[newest[0].Version] = true
+	for _, n := range p.Pinned {
+		keep[n] = true
+	}
+	for i, v := range newest {
+		if i < p.KeepLast {
+			keep[v.Version] = true
+		}
+		if p.KeepWithin > 0 && !v.Created.Before(now.Add(-p.KeepWithin)) {
+			keep[v.Version] = true
+		}
+	}
+
+	keepPeriods(keep, newest, p.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(keep, newest, p.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	return keep
+}
+
+// keepPeriods keeps the newest version of each of the n most recent periods
+func keepPeriods(keep map[int]bool, newest []HistoryVersion, n int, period func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, v := range newest {
+		if len(seen) >= n {
+			return
+		}
+		p := period(v.Created)
+		if !seen[p] {
+			seen[p] = true
+			keep[v.Version] = true
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package onlyoffice_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+// newPruneClient returns a client with one version of "a.docx" for each of
+// the given offsets from noon today, oldest first. Every version has a
+// document and changes.
+func newPruneClient(t *testing.T, offsets ...time.Duration) *onlyoffice.Client {
+	t.Helper()
+
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("changes"))
+	}))
+	t.Cleanup(files.Close)
+
+	st := storage.NewMemory()
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{Storage: st})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noon := time.Now().UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)
+	for _, offset := range offsets {
+		if _, err := st.Put(context.Background(), "a.docx", strings.NewReader("content")); err != nil {
+			t.Fatal(err)
+		}
+		_, err := client.CreateHistory("a.docx", models.Callback{
+			Key:        "k",
+			ChangesUrl: files.URL + "/changes.zip",
+			History:    models.History{Created: noon.Add(offset).Format("2006-01-02 15:04:05")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func TestPrune(t *testing.T) {
+	day := 24 * time.Hour
+	offsets := []time.Duration{-60 * day, -30 * day, -3 * day, -2*day - time.Hour, -2 * day, -time.Hour}
+
+	tests := []struct {
+		name        string
+		policy      onlyoffice.RetentionPolicy
+		removed     []int
+		invalidated []int
+	}{
+		{"zero policy keeps all", onlyoffice.RetentionPolicy{}, []int{}, nil},
+		{"keep last", onlyoffice.RetentionPolicy{KeepLast: 2}, []int{1, 2, 3, 4}, nil},
+		{"keep within", onlyoffice.RetentionPolicy{KeepWithin: 4 * day}, []int{1, 2}, nil},
+		{"keep daily", onlyoffice.RetentionPolicy{KeepDaily: 3}, []int{1, 2, 4}, []int{3}},
+		{"keep weekly", onlyoffice.RetentionPolicy{KeepWeekly: 52}, nil, nil}, // depends on the weekday
+		{"pinned", onlyoffice.RetentionPolicy{KeepLast: 1, Pinned: []int{2}}, []int{1, 3, 4, 5}, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newPruneClient(t, offsets...)
+
+			dry, err := client.Prune("a.docx", tt.policy, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.removed != nil {
+				if !reflect.DeepEqual(dry.Removed, tt.removed) {
+					t.Errorf("Removed = %v, want %v", dry.Removed, tt.removed)
+				}
+				if !reflect.DeepEqual(dry.Invalidated, tt.invalidated) {
+					t.Errorf("Invalidated = %v, want %v", dry.Invalidated, tt.invalidated)
+				}
+			}
+			if n, err := client.CountVersion("a.docx"); err != nil || n != len(offsets) {
+				t.Fatalf("Dry run removed versions: %d left, %v", n, err)
+			}
+
+			res, err := client.Prune("a.docx", tt.policy, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Removed, dry.Removed) || !reflect.DeepEqual(res.Kept, dry.Kept) {
+				t.Errorf("Prune %+v differs from dry run %+v", res, dry)
+			}
+
+			versions, err := client.GetHistory("a.docx")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kept []int
+			for _, v := range versions {
+				kept = append(kept, v.Version)
+				hasChanges := len(v.Artifacts) == 2
+				invalidated := false
+				for _, n := range res.Invalidated {
+					invalidated = invalidated || n == v.Version
+				}
+				if hasChanges == invalidated {
+					t.Errorf("Version %d artifacts %v, invalidated %v", v.Version, v.Artifacts, invalidated)
+				}
+			}
+			if !reflect.DeepEqual(kept, res.Kept) {
+				t.Errorf("Remaining versions %v, want %v", kept, res.Kept)
+			}
+		})
+	}
+}
+
+func TestPruneKeepsNumbering(t *testing.T) {
+	client := newPruneClient(t, -time.Hour, -time.Hour, -time.Hour)
+
+	if _, err := client.Prune("a.docx", onlyoffice.RetentionPolicy{KeepLast: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	v, err := client.CreateHistory("a.docx", models.Callback{Key: "k4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 4 {
+		t.Errorf("Expected version 4 after pruning, got %d", v.Version)
+	}
+}
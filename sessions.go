@@ -0,0 +1,234 @@
+package onlyoffice
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+// EditingSession is a user editing a document
+type EditingSession struct {
+	Key          string    `json:"key"`
+	UserID       string    `json:"userId"`
+	Since        time.Time `json:"since"`
+	LastActivity time.Time `json:"lastActivity"`
+}
+
+// PresenceEventType is the kind of a PresenceEvent
+type PresenceEventType string
+
+const (
+	PresenceJoin  PresenceEventType = "join"  // user started editing
+	PresenceLeave PresenceEventType = "leave" // user stopped editing
+)
+
+// PresenceEvent reports a user joining or leaving a document
+type PresenceEvent struct {
+	Type   PresenceEventType `json:"type"`
+	Key    string            `json:"key"`
+	UserID string            `json:"userId"`
+	At     time.Time         `json:"at"`
+}
+
+// SessionTracker maintains who is editing which document from the callbacks
+// the Document Server sends. Attach it to a handler with WithSessionTracker.
+type SessionTracker struct {
+	mu        sync.Mutex
+	docs      map[string]map[string]*EditingSession // document key -> user id
+	subs      map[chan PresenceEvent]struct{}
+	listeners []func(ev PresenceEvent, editors int)
+}
+
+// NewSessionTracker returns an empty SessionTracker
+func NewSessionTracker() *SessionTracker {
+	return &SessionTracker{
+		docs: make(map[string]map[string]*EditingSession),
+		subs: make(map[chan PresenceEvent]struct{}),
+	}
+}
+
+// WithSessionTracker makes the handler feed every callback to t
+func WithSessionTracker(t *SessionTracker) CallbackOption {
+	return func(h *callbackHandler) {
+		h.sessions = t
+	}
+}
+
+// Observe updates the sessions from a callback received at the given time
+func (t *SessionTracker) Observe(cb *models.Callback, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch cb.Status {
+	case models.StatusMustSave, models.StatusSaveError, models.StatusClosed:
+		// The editing session is over
+		for _, id := range t.userIDs(cb.Key) {
+			t.leave(cb.Key, id, at)
+		}
+		return
+	}
+
+	for _, a := range cb.Actions {
+		switch a.Type {
+		case models.ActionConnect:
+			t.join(cb.Key, a.UserID, at)
+		case models.ActionDisconnect:
+			t.leave(cb.Key, a.UserID, at)
+		case models.ActionForceSave:
+			t.touch(cb.Key, a.UserID, at)
+		}
+	}
+
+	// The users list is the authoritative set of editors
+	if cb.Users != nil {
+		present := make(map[string]bool, len(cb.Users))
+		for _, id := range cb.Users {
+			present[id] = true
+			t.touch(cb.Key, id, at)
+		}
+		for _, id := range t.userIDs(cb.Key) {
+			if !present[id] {
+				t.leave(cb.Key, id, at)
+			}
+		}
+	}
+}
+
+// userIDs returns the editors of a document in a stable order
+func (t *SessionTracker) userIDs(key string) []string {
+	ids := make([]string, 0, len(t.docs[key]))
+	for id := range t.docs[key] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// join starts a session unless the user is already editing
+func (t *SessionTracker) join(key, userID string, at time.Time) {
+	if userID == "" {
+		return
+	}
+	users := t.docs[key]
+	if users == nil {
+		users = make(map[string]*EditingSession)
+		t.docs[key] = users
+	}
+	if s, ok := users[userID]; ok {
+		s.LastActivity = at
+		return
+	}
+
+	users[userID] = &EditingSession{Key: key, UserID: userID, Since: at, LastActivity: at}
+	t.publish(PresenceEvent{Type: PresenceJoin, Key: key, UserID: userID, At: at})
+}
+
+// touch records activity, starting a session if needed
+func (t *SessionTracker) touch(key, userID string, at time.Time) {
+	if s, ok := t.docs[key][userID]; ok {
+		if at.After(s.LastActivity) {
+			s.LastActivity = at
+		}
+		return
+	}
+	t.join(key, userID, at)
+}
+
+// leave ends a session
+func (t *SessionTracker) leave(key, userID string, at time.Time) {
+	users := t.docs[key]
+	if _, ok := users[userID]; !ok {
+		return
+	}
+
+	delete(users, userID)
+	if len(users) == 0 {
+		delete(t.docs, key)
+	}
+	t.publish(PresenceEvent{Type: PresenceLeave, Key: key, UserID: userID, At: at})
+}
+
+// listen registers fn to be called synchronously, with the tracker locked,
+// for every presence event and the number of editors left on the document
+func (t *SessionTracker) listen(fn func(ev PresenceEvent, editors int)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.listeners = append(t.listeners, fn)
+}
+
+// publish sends ev to the listeners and subscribers. Subscribers that are
+// not keeping up miss the event rather than blocking callback processing.
+func (t *SessionTracker) publish(ev PresenceEvent) {
+	for _, fn := range t.listeners {
+		fn(ev, len(t.docs[ev.Key]))
+	}
+	for ch := range t.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// ActiveUsers returns the sessions on a document, oldest first
+func (t *SessionTracker) ActiveUsers(key string) []EditingSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []EditingSession
+	for _, s := range t.docs[key] {
+		out = append(out, *s)
+	}
+	sortSessions(out)
+	return out
+}
+
+// SessionsByUser returns the documents a user is editing, oldest first
+func (t *SessionTracker) SessionsByUser(userID string) []EditingSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []EditingSession
+	for _, users := range t.docs {
+		if s, ok := users[userID]; ok {
+			out = append(out, *s)
+		}
+	}
+	sortSessions(out)
+	return out
+}
+
+// Subscribe returns a channel receiving join and leave events, and a
+// function that cancels the subscription and closes the channel
+func (t *SessionTracker) Subscribe(buffer int) (<-chan PresenceEvent, func()) {
+	ch := make(chan PresenceEvent, buffer)
+
+	t.mu.Lock()
+	t.subs[ch] = struct{}{}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.subs, ch)
+			t.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func sortSessions(s []EditingSession) {
+	sort.Slice(s, func(i, j int) bool {
+		if !s[i].Since.Equal(s[j].Since) {
+			return s[i].Since.Before(s[j].Since)
+		}
+		if s[i].Key != s[j].Key {
+			return s[i].Key < s[j].Key
+		}
+		return s[i].UserID < s[j].UserID
+	})
+}
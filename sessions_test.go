@@ -0,0 +1,56 @@
+package onlyoffice_test
+
+import (
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+)
+
+func TestSessionTracker(t *testing.T) {
+	client := newTestClient(t)
+	tracker := onlyoffice.NewSessionTracker()
+	events, cancel := tracker.Subscribe(10)
+	defer cancel()
+
+	h := client.CallbackHandler(onlyoffice.CallbackHandlers{}, onlyoffice.WithSessionTracker(tracker))
+
+	postCallback(h, `{"status": 1, "key": "doc1", "users": ["alice"], "actions": [{"type": 1, "userid": "alice"}]}`)
+	postCallback(h, `{"status": 1, "key": "doc1", "users": ["alice", "bob"], "actions": [{"type": 1, "userid": "bob"}]}`)
+	postCallback(h, `{"status": 1, "key": "doc2", "users": ["bob"], "actions": [{"type": 1, "userid": "bob"}]}`)
+
+	active := tracker.ActiveUsers("doc1")
+	if len(active) != 2 || active[0].UserID != "alice" || active[1].UserID != "bob" {
+		t.Fatalf("Unexpected active users %+v", active)
+	}
+	if sessions := tracker.SessionsByUser("bob"); len(sessions) != 2 {
+		t.Errorf("Expected bob to edit two documents, got %+v", sessions)
+	}
+
+	postCallback(h, `{"status": 1, "key": "doc1", "users": ["bob"], "actions": [{"type": 0, "userid": "alice"}]}`)
+	if active := tracker.ActiveUsers("doc1"); len(active) != 1 || active[0].UserID != "bob" {
+		t.Errorf("Expected only bob after alice left, got %+v", active)
+	}
+
+	postCallback(h, `{"status": 2, "key": "doc1", "url": "https://example.com/out.docx"}`)
+	if active := tracker.ActiveUsers("doc1"); len(active) != 0 {
+		t.Errorf("Expected no editors after the document closed, got %+v", active)
+	}
+
+	var got []string
+	for len(events) > 0 {
+		ev := <-events
+		got = append(got, string(ev.Type)+":"+ev.Key+":"+ev.UserID)
+	}
+	want := []string{
+		"join:doc1:alice", "join:doc1:bob", "join:doc2:bob",
+		"leave:doc1:alice", "leave:doc1:bob",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Event %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
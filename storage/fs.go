@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tempPrefix marks files that are being written
+const tempPrefix = ".tmp-"
+
+// FS is a Storage backed by a local directory
+type FS struct {
+	root string
+}
+
+// NewFS returns a Storage that keeps objects as files below root
+func NewFS(root string) *FS {
+	return &FS{root: root}
+}
+
+// Root returns the directory holding the objects
+func (s *FS) Root() string {
+	return s.root
+}
+
+// path returns the file path of an object
+func (s *FS) path(name string) (string, string, error) {
+	name, err := CleanName(name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, filepath.Join(s.root, filepath.FromSlash(name)), nil
+}
+
+// info converts file metadata into ObjectInfo
+func (s *FS) info(name string, fi fs.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Name:        name,
+		Size:        fi.Size(),
+		ModTime:     fi.ModTime(),
+		ContentType: contentType(name),
+		ETag:        fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()),
+	}
+}
+
+// Put implements Storage. The content is written to a temporary file that is
+// synced and renamed into place.
+func (s *FS) Put(ctx context.Context, name string, r io.Reader) (*ObjectInfo, error) {
+	name, target, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), tempPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return nil, err
+	}
+	syncDir(filepath.Dir(target))
+
+	return s.Stat(ctx, name)
+}
+
+// Get implements Storage. The returned reader is an *os.File.
+func (s *FS) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	name, file, err := s.path(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, nil, notExist("get", name)
+	}
+
+	return f, s.info(name, fi), nil
+}
+
+// Stat implements Storage
+func (s *FS) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	name, file, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, notExist("stat", name)
+	}
+	return s.info(name, fi), nil
+}
+
+// Delete implements Storage. Directories left empty are kept, as a
+// concurrent Put may be about to write into them.
+func (s *FS) Delete(ctx context.Context, name string) error {
+	_, file, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(file); err == nil && fi.IsDir() {
+		return notExist("delete", name)
+	}
+	return os.Remove(file)
+}
+
+// List implements Storage. The prefix is validated like object names, so
+// it cannot reach outside the root.
+func (s *FS) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the deepest directory fully contained in the prefix
+	dir := prefix
+	if i := strings.LastIndex(dir, "/"); i >= 0 {
+		dir = dir[:i]
+	} else {
+		dir = ""
+	}
+	start := filepath.Join(s.root, filepath.FromSlash(dir))
+
+	var out []ObjectInfo
+	err = filepath.WalkDir(start, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) || strings.HasPrefix(d.Name(), lockPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		out = append(out, *s.info(name, fi))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// syncDir flushes a directory so that a rename survives a crash. Platforms
+// that cannot sync directories are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Copy implements Storage
+func (s *FS) Copy(ctx context.Context, src, dst string) (*ObjectInfo, error) {
+	r, _, err := s.Get(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return s.Put(ctx, dst, r)
+}
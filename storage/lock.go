@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPrefix marks the lock files created by FS.Lock
+const lockPrefix = ".lock-"
+
+// Locker is implemented by storages that can lock names across processes.
+// Locks are advisory: they only exclude other callers of Lock.
+type Locker interface {
+	// Lock acquires an exclusive lock on name, waiting until it is available
+	// or ctx is done. The returned function releases the lock.
+	Lock(ctx context.Context, name string) (unlock func() error, err error)
+}
+
+// Lock implements Locker with a lock file next to name. The name does not
+// need to exist. On platforms without file locking the lock only guards
+// against other holders in the same process.
+func (s *FS) Lock(ctx context.Context, name string) (func() error, error) {
+	_, file, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, lockPrefix+filepath.Base(file)), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := 5 * time.Millisecond
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+
+	return func() error {
+		unlockFile(f)
+		return f.Close()
+	}, nil
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package storage
+
+import (
+	"os"
+	"sync"
+)
+
+// heldLocks tracks the lock files held by this process
+var heldLocks sync.Map
+
+// tryLockFile marks the file as locked within the process
+func tryLockFile(f *os.File) (bool, error) {
+	_, held := heldLocks.LoadOrStore(f.Name(), true)
+	return !held, nil
+}
+
+// unlockFile releases a lock taken by tryLockFile
+func unlockFile(f *os.File) {
+	heldLocks.Delete(f.Name())
+}
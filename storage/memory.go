@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory Storage, mainly for tests
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+	etag    string
+}
+
+// NewMemory returns an empty in-memory Storage
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]*memoryObject)}
+}
+
+// readSeekCloser adds a no-op Close to a bytes.Reader
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error { return nil }
+
+func (o *memoryObject) info(name string) *ObjectInfo {
+	return &ObjectInfo{
+		Name:        name,
+		Size:        int64(len(o.data)),
+		ModTime:     o.modTime,
+		ContentType: contentType(name),
+		ETag:        o.etag,
+	}
+}
+
+// Put implements Storage
+func (s *Memory) Put(ctx context.Context, name string, r io.Reader) (*ObjectInfo, error) {
+	name, err := CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	obj := &memoryObject{
+		data:    data,
+		modTime: time.Now(),
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}
+
+	s.mu.Lock()
+	s.objects[name] = obj
+	s.mu.Unlock()
+
+	return obj.info(name), nil
+}
+
+// get returns the object stored under name
+func (s *Memory) get(op, name string) (string, *memoryObject, error) {
+	name, err := CleanName(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.RLock()
+	obj, ok := s.objects[name]
+	s.mu.RUnlock()
+	if !ok {
+		return "", nil, notExist(op, name)
+	}
+	return name, obj, nil
+}
+
+// Get implements Storage. The returned reader implements io.Seeker.
+func (s *Memory) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	name, obj, err := s.get("get", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return readSeekCloser{bytes.NewReader(obj.data)}, obj.info(name), nil
+}
+
+// Stat implements Storage
+func (s *Memory) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	name, obj, err := s.get("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return obj.info(name), nil
+}
+
+// Delete implements Storage
+func (s *Memory) Delete(ctx context.Context, name string) error {
+	name, _, err := s.get("delete", name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.objects, name)
+	s.mu.Unlock()
+	return nil
+}
+
+// List implements Storage
+func (s *Memory) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	prefix, err := cleanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var out []ObjectInfo
+	for name, obj := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			out = append(out, *obj.info(name))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Copy implements Storage
+func (s *Memory) Copy(ctx context.Context, src, dst string) (*ObjectInfo, error) {
+	_, obj, err := s.get("copy", src)
+	if err != nil {
+		return nil, err
+	}
+	return s.Put(ctx, dst, bytes.NewReader(obj.data))
+}
@@ -0,0 +1,100 @@
+// Package storage defines the document storage used by the SDK for
+// documents, history and other artifacts, and provides local filesystem and
+// in-memory implementations. Object stores can be plugged in by implementing
+// Storage.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned for objects that do not exist. It matches
+// fs.ErrNotExist and os.ErrNotExist with errors.Is.
+var ErrNotExist = fs.ErrNotExist
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	ContentType string    `json:"contentType,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+}
+
+// Storage stores objects under slash-separated names such as
+// "docs/report.docx". Implementations must be safe for concurrent use.
+type Storage interface {
+	// Put stores the content of r under name, replacing any existing object.
+	// Readers never observe a partially written object.
+	Put(ctx context.Context, name string, r io.Reader) (*ObjectInfo, error)
+	// Get opens an object for reading. The reader also implements io.Seeker
+	// when the implementation supports random access.
+	Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat returns the metadata of an object
+	Stat(ctx context.Context, name string) (*ObjectInfo, error)
+	// Delete removes an object
+	Delete(ctx context.Context, name string) error
+	// List returns the objects whose name starts with prefix, sorted by name
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Copy duplicates the object src to dst
+	Copy(ctx context.Context, src, dst string) (*ObjectInfo, error)
+}
+
+// CleanName normalizes an object name and rejects names that escape the
+// storage root
+func CleanName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	cleaned := strings.TrimPrefix(path.Clean("/"+slashed), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	for _, elem := range strings.Split(slashed, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("invalid object name %q", name)
+		}
+	}
+	return cleaned, nil
+}
+
+// cleanPrefix validates a List prefix like CleanName, keeping a trailing
+// slash and allowing the empty prefix
+func cleanPrefix(prefix string) (string, error) {
+	slashed := strings.TrimLeft(strings.ReplaceAll(prefix, "\\", "/"), "/")
+	if slashed == "" {
+		return "", nil
+	}
+	cleaned, err := CleanName(slashed)
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix %q", prefix)
+	}
+	if strings.HasSuffix(slashed, "/") {
+		cleaned += "/"
+	}
+	return cleaned, nil
+}
+
+// contentType guesses the media type of an object from its extension
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// notExist returns an error for a missing object
+func notExist(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: ErrNotExist}
+}
+
+// IsNotExist reports whether err indicates a missing object
+func IsNotExist(err error) bool {
+	return errors.Is(err, ErrNotExist)
+}
@@ -0,0 +1,117 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/royalrick/go-onlyoffice/storage"
+)
+
+func testStorage(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	info, err := s.Put(ctx, "docs/a.docx", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if info.Name != "docs/a.docx" || info.Size != 5 || info.ETag == "" || info.ContentType == "" {
+		t.Errorf("Unexpected put info %+v", info)
+	}
+
+	r, info, err := s.Get(ctx, "/docs/a.docx")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	if _, ok := r.(io.Seeker); !ok {
+		t.Error("Expected a seekable reader")
+	}
+	r.Close()
+	if string(data) != "hello" || info.Size != 5 {
+		t.Errorf("Unexpected content %q %+v", data, info)
+	}
+
+	if _, err := s.Copy(ctx, "docs/a.docx", "docs/sub/b.docx"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	s.Put(ctx, "docsx/c.txt", strings.NewReader("other"))
+
+	list, err := s.List(ctx, "docs/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "docs/a.docx" || list[1].Name != "docs/sub/b.docx" {
+		t.Errorf("Unexpected list %+v", list)
+	}
+	if list, _ := s.List(ctx, "docs"); len(list) != 3 {
+		t.Errorf("Expected a bare prefix to match 3 objects, got %+v", list)
+	}
+
+	if err := s.Delete(ctx, "docs/sub/b.docx"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Stat(ctx, "docs/sub/b.docx"); !storage.IsNotExist(err) {
+		t.Errorf("Expected not exist after delete, got %v", err)
+	}
+	if err := s.Delete(ctx, "docs/sub/b.docx"); !storage.IsNotExist(err) {
+		t.Errorf("Expected not exist deleting twice, got %v", err)
+	}
+	if _, _, err := s.Get(ctx, "missing"); !storage.IsNotExist(err) {
+		t.Errorf("Expected not exist for a missing object, got %v", err)
+	}
+	if _, err := s.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Error("Expected names escaping the root to be rejected")
+	}
+	if _, err := s.List(ctx, "docs/../../"); err == nil {
+		t.Error("Expected prefixes escaping the root to be rejected")
+	}
+}
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	testStorage(t, storage.NewFS(dir))
+
+	// Deleting keeps directories, which a concurrent Put may write into
+	if _, err := os.Stat(filepath.Join(dir, "docs", "sub")); err != nil {
+		t.Errorf("Expected the directory to be kept, got %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, storage.NewMemory())
+}
+
+func TestFSLock(t *testing.T) {
+	s := storage.NewFS(t.TempDir())
+	ctx := context.Background()
+
+	unlock, err := s.Lock(ctx, "docs/a.docx")
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// A second holder waits until the lock is released
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Lock(short, "docs/a.docx"); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	if objects, _ := s.List(ctx, ""); len(objects) != 0 {
+		t.Errorf("Lock files must not be listed: %+v", objects)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = s.Lock(ctx, "docs/a.docx")
+	if err != nil {
+		t.Fatalf("Lock after release failed: %v", err)
+	}
+	unlock()
+}
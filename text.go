@@ -0,0 +1,285 @@
+package onlyoffice
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrTextUnsupported is returned by ExtractText for file types it cannot
+// read
+var ErrTextUnsupported = errors.New("onlyoffice: text extraction not supported for file type")
+
+// ExtractText returns the plain text of a document, one paragraph per line.
+// It reads docx, xlsx and pptx files locally, as well as plain text formats.
+// Spreadsheet rows become lines with tab separated cells, and presentation
+// slides are read in order.
+func ExtractText(data []byte, fileType string) (string, error) {
+	switch fileType = strings.ToLower(strings.TrimPrefix(fileType, ".")); fileType {
+	case "txt", "csv", "md":
+		return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+	case "docx", "docm", "dotx", "xlsx", "xlsm", "pptx", "pptm":
+	default:
+		return "", fmt.Errorf("%w %q", ErrTextUnsupported, fileType)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("extract text: %w", err)
+	}
+	var lines []string
+	switch fileType {
+	case "docx", "docm", "dotx":
+		lines, err = ooxmlParagraphs(zr, "word/document.xml")
+	case "xlsx", "xlsm":
+		lines, err = xlsxRows(zr)
+	case "pptx", "pptm":
+		for _, name := range numberedParts(zr, "ppt/slides/slide") {
+			slide, err := ooxmlParagraphs(zr, name)
+			if err != nil {
+				return "", err
+			}
+			lines = append(lines, slide...)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// openPart opens a part of an OOXML package
+func openPart(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("extract text: %s missing", name)
+}
+
+// numberedParts returns the parts named prefix<n>.xml in numeric order
+func numberedParts(zr *zip.Reader, prefix string) []string {
+	numbers := make(map[string]int)
+	var names []string
+	for _, f := range zr.File {
+		rest, ok := strings.CutPrefix(f.Name, prefix)
+		if !ok || path.Ext(rest) != ".xml" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(rest, ".xml"))
+		if err != nil {
+			continue
+		}
+		numbers[f.Name] = n
+		names = append(names, f.Name)
+	}
+	sort.Slice(names, func(i, j int) bool { return numbers[names[i]] < numbers[names[j]] })
+	return names
+}
+
+// mcNamespace is the markup compatibility namespace of alternate content
+const mcNamespace = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+
+// ooxmlParagraphs returns the text of the paragraphs (w:p or a:p) of a
+// WordprocessingML or DrawingML part. Text in deleted revisions is skipped,
+// as it uses w:delText, and so is the fallback copy of alternate content.
+// Paragraphs of text boxes become lines of their own.
+func ooxmlParagraphs(zr *zip.Reader, name string) ([]string, error) {
+	rc, err := openPart(zr, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// paragraph is the state of the enclosing paragraph, saved while a text
+	// box is read
+	type paragraph struct {
+		text   strings.Builder
+		inText bool
+		depth  int
+		runs   int
+	}
+	var (
+		lines []string
+		p     = &paragraph{}
+		outer []*paragraph
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extract text: %s: %w", name, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == mcNamespace && t.Name.Local == "Fallback" {
+				if err := dec.Skip(); err != nil {
+					return nil, fmt.Errorf("extract text: %s: %w", name, err)
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "txbxContent":
+				outer = append(outer, p)
+				p = &paragraph{}
+			case "p":
+				p.depth++
+			case "r":
+				p.runs++
+			case "t":
+				p.inText = p.depth > 0
+			case "tab":
+				// Tab stops in paragraph properties are tab elements too
+				if p.runs > 0 {
+					p.text.WriteByte('\t')
+				}
+			case "br", "cr":
+				if p.depth > 0 {
+					lines = append(lines, p.text.String())
+					p.text.Reset()
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "txbxContent":
+				if n := len(outer); n > 0 {
+					p, outer = outer[n-1], outer[:n-1]
+				}
+			case "p":
+				if p.depth--; p.depth == 0 {
+					lines = append(lines, p.text.String())
+					p.text.Reset()
+				}
+			case "r":
+				p.runs--
+			case "t":
+				p.inText = false
+			}
+		case xml.CharData:
+			if p.inText {
+				p.text.Write(t)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// xlsxRows returns the rows of every worksheet as tab separated cells
+func xlsxRows(zr *zip.Reader) ([]string, error) {
+	shared, err := xlsxSharedStrings(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, name := range numberedParts(zr, "xl/worksheets/sheet") {
+		rc, err := openPart(zr, name)
+		if err != nil {
+			return nil, err
+		}
+		var sheet struct {
+			Rows []struct {
+				Cells []struct {
+					Ref    string `xml:"r,attr"`
+					Type   string `xml:"t,attr"`
+					Value  string `xml:"v"`
+					Inline string `xml:"is>t"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		err = xml.NewDecoder(rc).Decode(&sheet)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("extract text: %s: %w", name, err)
+		}
+
+		for _, row := range sheet.Rows {
+			// Empty cells are left out of the sheet, so cells are placed by
+			// their reference
+			var cells []string
+			for _, c := range row.Cells {
+				col := len(cells)
+				if n := cellColumn(c.Ref); n >= col {
+					col = n
+				}
+				for len(cells) <= col {
+					cells = append(cells, "")
+				}
+				switch c.Type {
+				case "s":
+					if n, err := strconv.Atoi(c.Value); err == nil && n >= 0 && n < len(shared) {
+						cells[col] = shared[n]
+					}
+				case "inlineStr":
+					cells[col] = c.Inline
+				default:
+					cells[col] = c.Value
+				}
+			}
+			lines = append(lines, strings.Join(cells, "\t"))
+		}
+	}
+	return lines, nil
+}
+
+// maxColumns is the number of columns of a worksheet
+const maxColumns = 16384
+
+// cellColumn returns the zero-based column of a cell reference such as
+// "C5", or -1 when the reference is missing or invalid
+func cellColumn(ref string) int {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+		if col > maxColumns {
+			return -1
+		}
+	}
+	if i == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// xlsxSharedStrings returns the shared strings table of a workbook
+func xlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	rc, err := openPart(zr, "xl/sharedStrings.xml")
+	if err != nil {
+		// Workbooks without text cells have no table
+		return nil, nil
+	}
+	defer rc.Close()
+
+	var table struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&table); err != nil {
+		return nil, fmt.Errorf("extract text: shared strings: %w", err)
+	}
+	shared := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		text := item.Text
+		for _, r := range item.Runs {
+			text += r.Text
+		}
+		shared[i] = text
+	}
+	return shared, nil
+}
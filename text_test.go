@@ -0,0 +1,87 @@
+package onlyoffice_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+)
+
+// newPackage returns a zip file with the given parts
+func newPackage(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	xlsx := newPackage(t, map[string]string{
+		"xl/sharedStrings.xml": `<sst><si><t>Name</t></si><si><r><t>Al</t></r><r><t>ice</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			`<row><c t="s"><v>0</v></c><c t="inlineStr"><is><t>Age</t></is></c></row>` +
+			`<row><c t="s"><v>1</v></c><c><v>30</v></c></row>` +
+			`</sheetData></worksheet>`,
+	})
+	slide := func(text string) string {
+		return `<p:sld><p:cSld><p:spTree><p:sp><p:txBody><a:p><a:r><a:t>` + text + `</a:t></a:r><a:br/><a:r><a:t>more</a:t></a:r></a:p></p:txBody></p:sp></p:spTree></p:cSld></p:sld>`
+	}
+	docx := newPackage(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="w" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"><w:body>` +
+			`<w:p><w:r><w:t>Before </w:t></w:r><w:r><mc:AlternateContent>` +
+			`<mc:Choice><w:drawing><w:txbxContent><w:p><w:r><w:t>Box</w:t></w:r></w:p></w:txbxContent></w:drawing></mc:Choice>` +
+			`<mc:Fallback><w:pict><w:txbxContent><w:p><w:r><w:t>Box</w:t></w:r></w:p></w:txbxContent></w:pict></mc:Fallback>` +
+			`</mc:AlternateContent></w:r><w:r><w:t>after</w:t></w:r></w:p>` +
+			`</w:body></w:document>`,
+	})
+	sparse := newPackage(t, map[string]string{
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="B1"><v>2</v></c><c r="D1"><v>4</v></c></row>` +
+			`</sheetData></worksheet>`,
+	})
+	pptx := newPackage(t, map[string]string{
+		"ppt/slides/slide10.xml": slide("Ten"),
+		"ppt/slides/slide2.xml":  slide("Two"),
+	})
+
+	tests := []struct {
+		name     string
+		data     []byte
+		fileType string
+		want     string
+	}{
+		{"xlsx", xlsx, "xlsx", "Name\tAge\nAlice\t30"},
+		{"SparseRow", sparse, "xlsx", "\t2\t\t4"},
+		{"TextBox", docx, "docx", "Box\nBefore after"},
+		{"pptx", pptx, "pptx", "Two\nmore\nTen\nmore"},
+		{"txt", []byte("a\r\nb\n"), ".TXT", "a\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := onlyoffice.ExtractText(tt.data, tt.fileType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := onlyoffice.ExtractText(nil, "odt"); !errors.Is(err, onlyoffice.ErrTextUnsupported) {
+		t.Errorf("Expected ErrTextUnsupported, got %v", err)
+	}
+}
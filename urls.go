@@ -0,0 +1,46 @@
+package onlyoffice
+
+import "strings"
+
+// documentServerInternalURL returns the base address this backend uses to
+// reach the Document Server.
+func (c *Client) documentServerInternalURL() string {
+	if c.config.DocumentServerInternalURL != "" {
+		return strings.TrimRight(c.config.DocumentServerInternalURL, "/")
+	}
+	return strings.TrimRight(c.config.DocumentServerURL, "/")
+}
+
+// toInternalDocumentServerURL rewrites a URL issued by the Document Server on
+// its public address so that this backend can fetch it.
+func (c *Client) toInternalDocumentServerURL(u string) string {
+	if c.config.DocumentServerInternalURL == "" {
+		return u
+	}
+	return rewriteURL(u, c.config.DocumentServerURL, c.config.DocumentServerInternalURL)
+}
+
+// toInternalStorageURL rewrites a public storage URL into the address the
+// Document Server uses to reach our storage.
+func (c *Client) toInternalStorageURL(u string) string {
+	if c.config.StorageInternalURL == "" || c.config.StorageURL == "" {
+		return u
+	}
+	return rewriteURL(u, c.config.StorageURL, c.config.StorageInternalURL)
+}
+
+// rewriteURL replaces the base from with to when u starts with it. The match
+// must end on a path boundary so that "http://a" does not match "http://ab".
+func rewriteURL(u, from, to string) string {
+	from = strings.TrimRight(from, "/")
+	to = strings.TrimRight(to, "/")
+	if from == "" || !strings.HasPrefix(u, from) {
+		return u
+	}
+
+	rest := u[len(from):]
+	if rest != "" && rest[0] != '/' && rest[0] != '?' && rest[0] != '#' {
+		return u
+	}
+	return to + rest
+}
@@ -0,0 +1,82 @@
+package onlyoffice_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/royalrick/go-onlyoffice"
+	"github.com/royalrick/go-onlyoffice/models"
+)
+
+func TestInternalURLRewriting(t *testing.T) {
+	var gotURL, gotPath string
+	ds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gotPath = r.URL.Path
+			w.Write([]byte("content"))
+			return
+		}
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		gotURL, _ = payload["url"].(string)
+		w.Write([]byte(`{"endConvert":true}`))
+	}))
+	defer ds.Close()
+
+	client, err := onlyoffice.NewClient(&onlyoffice.Config{
+		DocumentServerURL:         "https://docs.example.com",
+		DocumentServerInternalURL: ds.URL,
+		StorageURL:                "https://app.example.com",
+		StorageInternalURL:        "http://app:8080",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	cfg, err := client.BuildEditorConfig(models.EditorParams{
+		Filename:    "test.docx",
+		CallbackUrl: "https://app.example.com/callback",
+	}, "https://app.example.com/files/test.docx")
+	if err != nil {
+		t.Fatalf("Failed to build editor config: %v", err)
+	}
+	if cfg.Document.Url != "http://app:8080/files/test.docx" {
+		t.Errorf("Unexpected document url %q", cfg.Document.Url)
+	}
+	if cfg.EditorConfig.CallbackUrl != "http://app:8080/callback" {
+		t.Errorf("Unexpected callback url %q", cfg.EditorConfig.CallbackUrl)
+	}
+
+	if _, err := client.ConvertDocument(onlyoffice.ConvertOptions{
+		DocumentURL: "https://app.example.com/files/test.docx",
+		ToExt:       "pdf",
+	}); err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	if gotURL != "http://app:8080/files/test.docx" {
+		t.Errorf("Unexpected conversion url %q", gotURL)
+	}
+
+	callback, err := client.ParseCallback([]byte(`{
+		"status": 2,
+		"key": "k",
+		"url": "https://docs.example.com/cache/files/out.docx",
+		"changesurl": "https://docs.example.comx/changes.zip"
+	}`), "")
+	if err != nil {
+		t.Fatalf("Failed to parse callback: %v", err)
+	}
+	if callback.Url != "https://docs.example.com/cache/files/out.docx" {
+		t.Errorf("Callback urls must be kept as sent, got %q", callback.Url)
+	}
+
+	// Downloads go to the internal address, once
+	if _, err := client.DownloadFile(callback.Url); err != nil || gotPath != "/cache/files/out.docx" {
+		t.Errorf("Unexpected download of %q (%v)", gotPath, err)
+	}
+	if _, err := client.DownloadFile(ds.URL + "/cache/files/again.docx"); err != nil || gotPath != "/cache/files/again.docx" {
+		t.Errorf("Internal urls must not be rewritten again, got %q (%v)", gotPath, err)
+	}
+}